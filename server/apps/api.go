@@ -4,14 +4,22 @@
 package apps
 
 import (
+	"context"
+	"time"
+
 	"github.com/dgrijalva/jwt-go"
 	pluginapi "github.com/mattermost/mattermost-plugin-api"
+	"github.com/mattermost/mattermost-plugin-api/experimental/oauther"
 	"github.com/mattermost/mattermost-plugin-apps/server/configurator"
 	"github.com/mattermost/mattermost-plugin-apps/server/utils/md"
 )
 
 const OutgoingAuthHeader = "Mattermost-App-Authorization"
 
+// BindingsTTLHeader is set by the Apps in the bindings responses to override
+// Manifest.BindingsTTL, in seconds.
+const BindingsTTLHeader = "Mattermost-App-Bindings-TTL"
+
 type Service struct {
 	Configurator configurator.Service
 	Mattermost   *pluginapi.Client
@@ -22,20 +30,43 @@ type SessionToken string
 
 type API interface {
 	Call(*Call) (*CallResponse, error)
+	Lookup(*Call) ([]SelectOption, error)
 	GetBindings(*Context) ([]*Binding, error)
+	GetBindingsErrors() map[AppID]*BindingsError
+	InvalidateBindings(AppID) error
 	InstallApp(*Context, SessionToken, *InInstallApp) (*App, md.MD, error)
+	UninstallApp(AppID) error
+	MigrateAppSubsIndex() error
 	Notify(cc *Context, subj Subject) error
+	PollLifecycleChanges() error
+	CheckSubscriptions() error
+	RegisterCommands()
+	ExecuteCommand(cc *Context, command string) (*CallResponse, error)
 	ProvisionApp(*Context, SessionToken, *InProvisionApp) (*App, md.MD, error)
 	Subscribe(*Subscription) error
 	Unsubscribe(*Subscription) error
+	ListSubscriptions(AppID) ([]*Subscription, error)
+	UnsubscribeAll(AppID) error
 
 	ListApps() []*App
 	GetApp(appID AppID) (*App, error)
+	GetAppByBotUserID(botUserID string) (*App, error)
+	AuthenticateAppJWT(token string) (*App, error)
 	StoreApp(app *App) error
+	DeleteApp(appID AppID) error
+
+	GetOAuther(AppID) (oauther.OAuther, error)
+	StartOAuth2Connect(appID AppID, actingUserID string, callOnComplete *Call) (string, error)
+
+	ConfigureRemoteOAuth2(appID AppID, providerID string, client *RemoteOAuth2Client) error
+	StartRemoteOAuth2Connect(appID AppID, providerID, userID string) (string, error)
+	CompleteRemoteOAuth2Connect(appID AppID, providerID, userID, state, code string) error
 }
 
 type Client interface {
-	GetBindings(*Context) ([]*Binding, error)
+	// GetBindings also returns the TTL the App set in the BindingsTTLHeader,
+	// or 0. The request is cancelled with the context.Context.
+	GetBindings(context.Context, *Context) ([]*Binding, time.Duration, error)
 	GetManifest(manifestURL string) (*Manifest, error)
 	PostCall(*Call) (*CallResponse, error)
 	PostNotification(*Notification) error
@@ -44,6 +75,10 @@ type Client interface {
 type JWTClaims struct {
 	jwt.StandardClaims
 	ActingUserID string `json:"acting_user_id,omitempty"`
+
+	// AppID is set in the tokens that Apps issue to authenticate to the
+	// proxy, signed with the App's secret.
+	AppID AppID `json:"app_id,omitempty"`
 }
 
 type InInstallApp struct {
@@ -19,6 +19,15 @@ type Manifest struct {
 	// application intends to bind to, e.g. `{"/post_menu", "/channel_header",
 	// "/command/apptrigger"}``.
 	RequestedLocations Locations `json:"requested_locations,omitempty"`
+
+	// RemoteOAuth2Providers are the third-party OAuth2 providers that the
+	// App's users connect their accounts to, see RemoteOAuth2Provider.
+	RemoteOAuth2Providers []*RemoteOAuth2Provider `json:"remote_oauth2_providers,omitempty"`
+
+	// BindingsTTL is how long, in seconds, the proxy may cache the App's
+	// bindings for a context. The App can override it per response with the
+	// BindingsTTLHeader. The bindings are not cached by default.
+	BindingsTTL int `json:"bindings_ttl,omitempty"`
 }
 
 type App struct {
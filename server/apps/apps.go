@@ -4,6 +4,9 @@
 package apps
 
 import (
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+
 	"github.com/mattermost/mattermost-plugin-apps/server/utils"
 )
 
@@ -49,3 +52,63 @@ func (s *Service) StoreApp(app *App) error {
 
 	return s.Configurator.Store(conf.StoredConfig)
 }
+
+func (s *Service) DeleteApp(appID AppID) error {
+	conf := s.Configurator.GetConfig()
+	if conf.Apps[string(appID)] == nil {
+		return utils.ErrNotFound
+	}
+
+	delete(conf.Apps, string(appID))
+
+	err := s.Configurator.Refresh(conf.StoredConfig)
+	if err != nil {
+		return err
+	}
+
+	return s.Configurator.Store(conf.StoredConfig)
+}
+
+// GetAppByBotUserID returns the App that owns the bot user.
+func (s *Service) GetAppByBotUserID(botUserID string) (*App, error) {
+	if botUserID == "" {
+		return nil, utils.ErrNotFound
+	}
+	for _, app := range s.ListApps() {
+		if app.BotUserID == botUserID {
+			return app, nil
+		}
+	}
+	return nil, utils.ErrNotFound
+}
+
+// AuthenticateAppJWT verifies a token issued by an App to call the proxy. The
+// token must identify the App, be signed with its secret, and expire.
+func (s *Service) AuthenticateAppJWT(token string) (*App, error) {
+	var app *App
+	claims := JWTClaims{}
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		if claims.AppID == "" {
+			return nil, errors.New("app_id is required")
+		}
+		if claims.ExpiresAt == 0 {
+			return nil, errors.New("exp is required")
+		}
+		var err error
+		app, err = s.GetApp(claims.AppID)
+		if err != nil {
+			return nil, err
+		}
+		if app.Secret == "" {
+			return nil, errors.Errorf("app %s has no secret", claims.AppID)
+		}
+		return []byte(app.Secret), nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to authenticate app")
+	}
+	return app, nil
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package apps
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/configurator"
+)
+
+func newTestAppsService(apps ...*App) *Service {
+	stored := &configurator.StoredConfig{
+		Apps: map[string]interface{}{},
+	}
+	for _, app := range apps {
+		stored.Apps[string(app.Manifest.AppID)] = app.ConfigMap()
+	}
+	return &Service{
+		Configurator: configurator.NewTestConfigurator(&configurator.Config{
+			StoredConfig: stored,
+		}),
+	}
+}
+
+func TestGetAppByBotUserID(t *testing.T) {
+	s := newTestAppsService(
+		&App{Manifest: &Manifest{AppID: "app1"}, BotUserID: "bot1"},
+		&App{Manifest: &Manifest{AppID: "app2"}, BotUserID: "bot2"},
+	)
+
+	app, err := s.GetAppByBotUserID("bot2")
+	require.NoError(t, err)
+	require.Equal(t, AppID("app2"), app.Manifest.AppID)
+
+	_, err = s.GetAppByBotUserID("user")
+	require.Error(t, err)
+	_, err = s.GetAppByBotUserID("")
+	require.Error(t, err)
+}
+
+func TestAuthenticateAppJWT(t *testing.T) {
+	s := newTestAppsService(
+		&App{Manifest: &Manifest{AppID: "app1"}, Secret: "secret1"},
+		&App{Manifest: &Manifest{AppID: "app2"}},
+	)
+	expiresAt := time.Now().Add(time.Minute).Unix()
+
+	for name, tc := range map[string]struct {
+		claims        JWTClaims
+		method        jwt.SigningMethod
+		secret        string
+		expectedError string
+	}{
+		"happy": {
+			claims: JWTClaims{AppID: "app1", StandardClaims: jwt.StandardClaims{ExpiresAt: expiresAt}},
+			secret: "secret1",
+		},
+		"wrong secret": {
+			claims:        JWTClaims{AppID: "app1", StandardClaims: jwt.StandardClaims{ExpiresAt: expiresAt}},
+			secret:        "secret2",
+			expectedError: "failed to authenticate app: signature is invalid",
+		},
+		"missing app ID": {
+			claims:        JWTClaims{StandardClaims: jwt.StandardClaims{ExpiresAt: expiresAt}},
+			secret:        "secret1",
+			expectedError: "failed to authenticate app: app_id is required",
+		},
+		"unknown app": {
+			claims:        JWTClaims{AppID: "app3", StandardClaims: jwt.StandardClaims{ExpiresAt: expiresAt}},
+			secret:        "secret1",
+			expectedError: "failed to authenticate app: not found",
+		},
+		"app without secret": {
+			claims:        JWTClaims{AppID: "app2", StandardClaims: jwt.StandardClaims{ExpiresAt: expiresAt}},
+			expectedError: "failed to authenticate app: app app2 has no secret",
+		},
+		"no expiry": {
+			claims:        JWTClaims{AppID: "app1"},
+			secret:        "secret1",
+			expectedError: "failed to authenticate app: exp is required",
+		},
+		"expired": {
+			claims:        JWTClaims{AppID: "app1", StandardClaims: jwt.StandardClaims{ExpiresAt: time.Now().Add(-time.Minute).Unix()}},
+			secret:        "secret1",
+			expectedError: "failed to authenticate app: token is expired",
+		},
+		"unsigned": {
+			claims:        JWTClaims{AppID: "app1", StandardClaims: jwt.StandardClaims{ExpiresAt: expiresAt}},
+			method:        jwt.SigningMethodNone,
+			expectedError: "failed to authenticate app: unexpected signing method: none",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var token string
+			var err error
+			if tc.method == jwt.SigningMethodNone {
+				token, err = jwt.NewWithClaims(jwt.SigningMethodNone, tc.claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+			} else {
+				token, err = jwt.NewWithClaims(jwt.SigningMethodHS256, tc.claims).SignedString([]byte(tc.secret))
+			}
+			require.NoError(t, err)
+
+			app, err := s.AuthenticateAppJWT(token)
+			if tc.expectedError != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, AppID("app1"), app.Manifest.AppID)
+		})
+	}
+}
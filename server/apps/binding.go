@@ -27,11 +27,15 @@ type Binding struct {
 	// Description is the (optional) extended help text, used in modals and autocomplete
 	Description string `json:"description,omitempty"`
 
-	RoleID           string `json:"role_id,omitempty"`
-	DependsOnTeam    bool   `json:"depends_on_team,omitempty"`
-	DependsOnChannel bool   `json:"depends_on_channel,omitempty"`
-	DependsOnUser    bool   `json:"depends_on_user,omitempty"`
-	DependsOnPost    bool   `json:"depends_on_post,omitempty"`
+	// RoleID, if set, restricts the binding and its sub-bindings to the users
+	// that have the system, team or channel role, e.g. "team_admin". Calls to
+	// the binding are rejected for the other users.
+	RoleID string `json:"role_id,omitempty"`
+
+	DependsOnTeam    bool `json:"depends_on_team,omitempty"`
+	DependsOnChannel bool `json:"depends_on_channel,omitempty"`
+	DependsOnUser    bool `json:"depends_on_user,omitempty"`
+	DependsOnPost    bool `json:"depends_on_post,omitempty"`
 
 	// A Binding is either to a Call, or is a "container" for other locations -
 	// i.e. menu sub-items or subcommands. An app-defined Modal can be displayed
@@ -45,3 +49,10 @@ type Binding struct {
 	// TODO: Can embedded forms be mutable, and what does it mean?
 	Form *Form `json:"form,omitempty"`
 }
+
+// BindingsError is the last failure to fetch an App's bindings, for the
+// system administrators. At is in milliseconds since the epoch.
+type BindingsError struct {
+	Error string `json:"error"`
+	At    int64  `json:"at"`
+}
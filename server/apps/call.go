@@ -1,9 +1,12 @@
 package apps
 
 import (
+	"bytes"
 	"encoding/json"
 	"io"
 
+	"github.com/pkg/errors"
+
 	"github.com/mattermost/mattermost-plugin-apps/server/utils/md"
 )
 
@@ -18,25 +21,137 @@ const (
 	// CallTypeCancel is used for for the (rare?) case of when the form with
 	// SubmitOnCancel set is dismissed by the user.
 	CallTypeCancel = CallType("cancel")
+	// CallTypeLookup fetches the options of a FieldTypeDynamicSelect field
+	// from its SelectSourceURL, for the user's partial input in Query.
+	CallTypeLookup = CallType("lookup")
 )
 
+// ErrLookupSuperseded is returned for the lookups that were not sent to the
+// App, because the user has made a newer one for the same field.
+var ErrLookupSuperseded = errors.New("superseded by a newer lookup")
+
 type Call struct {
-	URL        string            `json:"url,omitempty"`
-	Type       CallType          `json:"type,omitempty"`
-	Values     map[string]string `json:"values,omitempty"`
-	Context    *Context          `json:"context,omitempty"`
-	RawCommand string            `json:"raw_command,omitempty"`
-	Expand     *Expand           `json:"expand,omitempty"`
+	URL        string     `json:"url,omitempty"`
+	Type       CallType   `json:"type,omitempty"`
+	Values     CallValues `json:"values,omitempty"`
+	Context    *Context   `json:"context,omitempty"`
+	RawCommand string     `json:"raw_command,omitempty"`
+	Expand     *Expand    `json:"expand,omitempty"`
+
+	// Used in CallTypeLookup, the Name of the field, and the user's input.
+	SelectedField string `json:"selected_field,omitempty"`
+	Query         string `json:"query,omitempty"`
+}
+
+// CallValues are the values of the Call's fields, by the field Name. A value
+// is a string, or a list of strings for the fields with SelectIsMulti set.
+// Numbers and bools in JSON are decoded as strings, and nulls are dropped.
+type CallValues map[string]interface{}
+
+func (v *CallValues) UnmarshalJSON(data []byte) error {
+	raw := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err := decoder.Decode(&raw)
+	if err != nil {
+		return err
+	}
+	if raw == nil {
+		*v = nil
+		return nil
+	}
+
+	values := CallValues{}
+	for name, value := range raw {
+		if list, ok := value.([]interface{}); ok {
+			strs := []string{}
+			for _, item := range list {
+				s, ok := callValueString(item)
+				if !ok {
+					return errors.Errorf("invalid value in the list for %s", name)
+				}
+				strs = append(strs, s)
+			}
+			values[name] = strs
+			continue
+		}
+		if value == nil {
+			continue
+		}
+		s, ok := callValueString(value)
+		if !ok {
+			return errors.Errorf("invalid value for %s, must be a string or a list", name)
+		}
+		values[name] = s
+	}
+	*v = values
+	return nil
+}
+
+func callValueString(value interface{}) (string, bool) {
+	switch value := value.(type) {
+	case string:
+		return value, true
+	case json.Number:
+		return value.String(), true
+	case bool:
+		if value {
+			return "true", true
+		}
+		return "false", true
+	}
+	return "", false
+}
+
+// Get returns the string value, or "" if it is not set or is a list.
+func (v CallValues) Get(name string) string {
+	s, _ := v[name].(string)
+	return s
+}
+
+// GetList returns the list value, a single string value as a list of one, or
+// nil if the value is not set.
+func (v CallValues) GetList(name string) []string {
+	switch value := v[name].(type) {
+	case string:
+		if value == "" {
+			return nil
+		}
+		return []string{value}
+	case []string:
+		if len(value) == 0 {
+			return nil
+		}
+		return value
+	}
+	return nil
+}
+
+// Equal returns true if the value of name is the same in both.
+func (v CallValues) Equal(other CallValues, name string) bool {
+	a, b := v.GetList(name), other.GetList(name)
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
 }
 
 type CallResponseType string
 
 const (
-	CallResponseTypeOK       = CallResponseType("")
-	CallResponseTypeError    = CallResponseType("error")
-	CallResponseTypeForm     = CallResponseType("form")
-	CallResponseTypeCall     = CallResponseType("call")
-	CallResponseTypeNavigate = CallResponseType("navigate")
+	CallResponseTypeOK    = CallResponseType("")
+	CallResponseTypeError = CallResponseType("error")
+	CallResponseTypeForm  = CallResponseType("form")
+	// CallResponseTypeFormUpdate patches the form that the user has open,
+	// with the fields in Form. See Form.Update.
+	CallResponseTypeFormUpdate = CallResponseType("form_update")
+	CallResponseTypeCall       = CallResponseType("call")
+	CallResponseTypeNavigate   = CallResponseType("navigate")
 )
 
 type CallResponse struct {
@@ -46,9 +161,15 @@ type CallResponse struct {
 	Markdown md.MD                  `json:"markdown,omitempty"`
 	Data     map[string]interface{} `json:"data,omitempty"`
 
-	// Used in CallResponseTypeError
+	// Used in CallResponseTypeError, and in CallResponseTypeForm to explain
+	// FieldErrors.
 	Error string `json:"error,omitempty"`
 
+	// FieldErrors are the errors in the submitted values, by the field Name.
+	// Used in CallResponseTypeForm and CallResponseTypeError, to show them by
+	// the fields.
+	FieldErrors map[string]string `json:"field_errors,omitempty"`
+
 	// Used in CallResponseTypeNavigate
 	NavigateToURL      string `json:"navigate_to_url,omitempty"`
 	UseExternalBrowser bool   `json:"use_external_browser,omitempty"`
@@ -56,8 +177,15 @@ type CallResponse struct {
 	// Used in CallResponseTypeCall
 	Call *Call `json:"call,omitempty"`
 
-	// Used in CallResponseTypeForm
+	// Used in CallResponseTypeForm, and CallResponseTypeFormUpdate
 	Form *Form `json:"form,omitempty"`
+
+	// Options are the result of CallTypeLookup.
+	Options []SelectOption `json:"options,omitempty"`
+
+	// RefreshBindings invalidates the App's cached bindings, and tells the
+	// clients to fetch them again.
+	RefreshBindings bool `json:"refresh_bindings,omitempty"`
 }
 
 func UnmarshalCallFromData(data []byte) (*Call, error) {
@@ -83,7 +211,7 @@ func MakeCall(url string, namevalues ...string) *Call {
 		URL: url,
 	}
 
-	values := map[string]string{}
+	values := CallValues{}
 	for len(namevalues) > 0 {
 		switch len(namevalues) {
 		case 1:
@@ -102,8 +230,8 @@ func MakeCall(url string, namevalues ...string) *Call {
 }
 
 func (c *Call) GetValue(name, defaultValue string) string {
-	if len(c.Values) == 0 || c.Values[name] == "" {
+	if c.Values.Get(name) == "" {
 		return defaultValue
 	}
-	return c.Values[name]
+	return c.Values.Get(name)
 }
@@ -32,3 +32,27 @@ func TestUnmarshalCallRequest(t *testing.T) {
 	require.Equal(t, "b3snp6tk6pbh9fxjpbhqn5hzgh", data.Values[PropBotAccessToken])
 	require.Equal(t, "cywc3e8nebyujrpuip98t69a3h", data.Values[PropOAuth2ClientSecret])
 }
+
+func TestUnmarshalCallValues(t *testing.T) {
+	c, err := UnmarshalCallFromData([]byte(`{"values": {"text": "a", "count": 1.50, "bool": true, "none": null, "users": ["u1", "u2"], "empty": []}}`))
+	require.NoError(t, err)
+	require.Equal(t, CallValues{
+		"text":  "a",
+		"count": "1.50",
+		"bool":  "true",
+		"users": []string{"u1", "u2"},
+		"empty": []string{},
+	}, c.Values)
+	require.Equal(t, "a", c.GetValue("text", "x"))
+	require.Equal(t, "x", c.GetValue("users", "x"))
+	require.Equal(t, []string{"u1", "u2"}, c.Values.GetList("users"))
+	require.Equal(t, []string{"a"}, c.Values.GetList("text"))
+	require.Nil(t, c.Values.GetList("empty"))
+	require.True(t, c.Values.Equal(CallValues{"text": []string{"a"}}, "text"))
+	require.False(t, c.Values.Equal(CallValues{"users": []string{"u2", "u1"}}, "users"))
+
+	_, err = UnmarshalCallFromData([]byte(`{"values": {"users": [{"id": "u1"}]}}`))
+	require.EqualError(t, err, "invalid value in the list for users")
+	_, err = UnmarshalCallFromData([]byte(`{"values": {"user": {"id": "u1"}}}`))
+	require.EqualError(t, err, "invalid value for user, must be a string or a list")
+}
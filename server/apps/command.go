@@ -0,0 +1,230 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package apps
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// CommandResolver looks up the users and channels referenced in the command
+// arguments by @username and ~channel-name, or by ID, and returns their IDs.
+// The channels are only found if the acting user is a member.
+type CommandResolver interface {
+	ResolveUser(username string) (string, error)
+	ResolveUserID(userID string) (string, error)
+	ResolveChannel(name string) (string, error)
+	ResolveChannelID(channelID string) (string, error)
+}
+
+// CommandError is returned when the command arguments do not fit the form.
+// FieldErrors are by the field Name.
+type CommandError struct {
+	Message     string
+	FieldErrors map[string]string
+}
+
+func (e *CommandError) Error() string {
+	if len(e.FieldErrors) == 0 {
+		return e.Message
+	}
+	names := []string{}
+	for name := range e.FieldErrors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	errs := []string{}
+	for _, name := range names {
+		errs = append(errs, name+": "+e.FieldErrors[name])
+	}
+	return e.Message + ": " + strings.Join(errs, ", ")
+}
+
+// SplitCommand splits a command into words by whitespace. The words can be
+// quoted with " or ', and \ escapes the next character outside of the single
+// quotes.
+func SplitCommand(command string) ([]string, error) {
+	words := []string{}
+	word := strings.Builder{}
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, c := range command {
+		switch {
+		case escaped:
+			word.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(c)
+		case c == '"' || c == '\'':
+			quote, inWord = c, true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.Errorf("unterminated %c quote", quote)
+	}
+	if escaped {
+		return nil, errors.New("unterminated \\ escape")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// ParseCommandValues maps the command arguments to the form's fields, by
+// AutocompletePosition for the positional arguments and by FlagName for the
+// --flag ones. The --flags of the multi-select fields can be repeated to add
+// values. The user and channel references are resolved to their IDs, and the
+// values are validated against the form. The errors in the values are
+// returned as a *CommandError.
+func ParseCommandValues(args []string, form *Form, resolver CommandResolver) (CallValues, error) {
+	flags := map[string]*Field{}
+	for _, f := range form.Fields {
+		if f.Type != FieldTypeMarkdown {
+			flags[f.FlagName()] = f
+		}
+	}
+	positional := form.PositionalFields()
+
+	values := CallValues{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") {
+			for len(positional) > 0 && values.GetList(positional[0].Name) != nil {
+				positional = positional[1:]
+			}
+			if len(positional) == 0 {
+				return nil, &CommandError{Message: fmt.Sprintf("unexpected argument %q", arg)}
+			}
+			addCommandValue(values, positional[0], arg)
+			positional = positional[1:]
+			continue
+		}
+
+		name, value := arg[2:], ""
+		hasValue := false
+		if n := strings.Index(name, "="); n >= 0 {
+			name, value, hasValue = name[:n], name[n+1:], true
+		}
+		f := flags[name]
+		if f == nil {
+			return nil, &CommandError{Message: fmt.Sprintf("unknown flag --%s", name)}
+		}
+		if !hasValue {
+			switch {
+			case f.Type == FieldTypeBool && (i+1 == len(args) || (args[i+1] != "true" && args[i+1] != "false")):
+				value = "true"
+			case i+1 == len(args):
+				return nil, &CommandError{
+					Message:     "invalid arguments",
+					FieldErrors: map[string]string{f.Name: fmt.Sprintf("--%s requires a value", name)},
+				}
+			default:
+				i++
+				value = args[i]
+			}
+		}
+		addCommandValue(values, f, value)
+	}
+
+	fieldErrors := map[string]string{}
+	for _, f := range form.Fields {
+		if values[f.Name] == nil {
+			continue
+		}
+		resolved := []string{}
+		for _, v := range values.GetList(f.Name) {
+			id, err := resolveValue(f, v, resolver)
+			if err != nil {
+				fieldErrors[f.Name] = err.Error()
+				break
+			}
+			resolved = append(resolved, id)
+		}
+		if fieldErrors[f.Name] != "" {
+			continue
+		}
+		if f.IsMulti() {
+			values[f.Name] = resolved
+		} else if len(resolved) > 0 {
+			values[f.Name] = resolved[0]
+		}
+	}
+	for name, msg := range form.ValidateValues(values) {
+		if fieldErrors[name] == "" {
+			fieldErrors[name] = msg
+		}
+	}
+	if len(fieldErrors) > 0 {
+		return nil, &CommandError{
+			Message:     "invalid arguments",
+			FieldErrors: fieldErrors,
+		}
+	}
+	return values, nil
+}
+
+// addCommandValue sets the value of the field, or adds it to the list for the
+// multi-select fields.
+func addCommandValue(values CallValues, f *Field, v string) {
+	if !f.IsMulti() {
+		values[f.Name] = v
+		return
+	}
+	list := append([]string{}, values.GetList(f.Name)...)
+	values[f.Name] = append(list, v)
+}
+
+// resolveValue resolves the @username and ~channel-name references to IDs.
+// The other values are looked up by ID if they look like one, since a name can
+// look like an ID too, and then by name.
+func resolveValue(f *Field, v string, resolver CommandResolver) (string, error) {
+	switch f.Type {
+	case FieldTypeUser:
+		id, err := resolveReference(v, "@", resolver.ResolveUser, resolver.ResolveUserID)
+		if err != nil {
+			return "", errors.Errorf("user %s not found", v)
+		}
+		return id, nil
+
+	case FieldTypeChannel:
+		id, err := resolveReference(v, "~", resolver.ResolveChannel, resolver.ResolveChannelID)
+		if err != nil {
+			return "", errors.Errorf("channel %s not found", v)
+		}
+		return id, nil
+	}
+	return v, nil
+}
+
+func resolveReference(v, prefix string, byName, byID func(string) (string, error)) (string, error) {
+	if strings.HasPrefix(v, prefix) {
+		return byName(strings.TrimPrefix(v, prefix))
+	}
+	if model.IsValidId(v) {
+		if id, err := byID(v); err == nil {
+			return id, nil
+		}
+	}
+	return byName(v)
+}
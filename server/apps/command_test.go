@@ -0,0 +1,219 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package apps
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// testResolver has bob, and a user whose username looks like an ID. The acting
+// user is a member of town-square, and of the channel with the ID-like name.
+type testResolver struct{}
+
+const testIDLikeName = "abcdefghijklmnopqrstuvwxyz"
+
+func (testResolver) ResolveUser(username string) (string, error) {
+	switch username {
+	case "bob":
+		return "bob-id", nil
+	case testIDLikeName:
+		return "id-like-user-id", nil
+	}
+	return "", errors.New("not found")
+}
+
+func (testResolver) ResolveUserID(userID string) (string, error) {
+	if userID == "zyxwvutsrqponmlkjihgfedcba" {
+		return userID, nil
+	}
+	return "", errors.New("not found")
+}
+
+func (testResolver) ResolveChannel(name string) (string, error) {
+	if name == "town-square" {
+		return "town-square-id", nil
+	}
+	return "", errors.New("not found")
+}
+
+func (testResolver) ResolveChannelID(channelID string) (string, error) {
+	if channelID == testIDLikeName {
+		return channelID, nil
+	}
+	return "", errors.New("not found")
+}
+
+func TestSplitCommand(t *testing.T) {
+	for command, expected := range map[string][]string{
+		"":                          {},
+		"/hello  message\thi ":      {"/hello", "message", "hi"},
+		`/hello "hi there" 'a "b"'`: {"/hello", "hi there", `a "b"`},
+		`--message="hi there"`:      {"--message=hi there"},
+		`a\ b "c\"d" 'e\f'`:         {"a b", `c"d`, `e\f`},
+		`""`:                        {""},
+	} {
+		words, err := SplitCommand(command)
+		require.NoError(t, err, command)
+		require.Equal(t, expected, words, command)
+	}
+
+	_, err := SplitCommand(`/hello "hi`)
+	require.EqualError(t, err, `unterminated " quote`)
+	_, err = SplitCommand(`/hello hi\`)
+	require.EqualError(t, err, `unterminated \ escape`)
+}
+
+func TestParseCommandValues(t *testing.T) {
+	form := &Form{
+		Fields: []*Field{
+			{
+				Name:  "userID",
+				Type:  FieldTypeUser,
+				Label: "user",
+			}, {
+				Name:  "channel",
+				Type:  FieldTypeChannel,
+				Label: "channel",
+			}, {
+				Name:                 "message",
+				Type:                 FieldTypeText,
+				Label:                "message",
+				IsRequired:           true,
+				TextMinLength:        2,
+				TextMaxLength:        5,
+				AutocompletePosition: 2,
+			}, {
+				Name:                 "title",
+				Type:                 FieldTypeText,
+				AutocompletePosition: 1,
+			}, {
+				Name:  "urgent",
+				Type:  FieldTypeBool,
+				Label: "urgent",
+			}, {
+				Name:          "cc",
+				Type:          FieldTypeUser,
+				SelectIsMulti: true,
+			}, {
+				Name:       "count",
+				Type:       FieldTypeNumber,
+				NumberStep: 1,
+			}, {
+				Name:        "note",
+				Type:        FieldTypeMarkdown,
+				Description: "**note**",
+			},
+		},
+	}
+
+	for name, tc := range map[string]struct {
+		args                []string
+		expected            CallValues
+		expectedError       string
+		expectedFieldErrors map[string]string
+	}{
+		"positional": {
+			args:     []string{"title", "hi"},
+			expected: CallValues{"title": "title", "message": "hi"},
+		},
+		"positional after flag": {
+			args:     []string{"--title", "title", "hi"},
+			expected: CallValues{"title": "title", "message": "hi"},
+		},
+		"flags": {
+			args:     []string{"--user", "@bob", "--message=hi", "--channel", "~town-square"},
+			expected: CallValues{"userID": "bob-id", "message": "hi", "channel": "town-square-id"},
+		},
+		"IDs and names": {
+			args:     []string{"--user", "bob", "--channel", "abcdefghijklmnopqrstuvwxyz", "--message", "hi"},
+			expected: CallValues{"userID": "bob-id", "message": "hi", "channel": "abcdefghijklmnopqrstuvwxyz"},
+		},
+		"user ID": {
+			args:     []string{"--user", "zyxwvutsrqponmlkjihgfedcba", "--message", "hi"},
+			expected: CallValues{"userID": "zyxwvutsrqponmlkjihgfedcba", "message": "hi"},
+		},
+		"username that looks like an ID": {
+			args:     []string{"--user", "abcdefghijklmnopqrstuvwxyz", "--cc", "@abcdefghijklmnopqrstuvwxyz", "--message", "hi"},
+			expected: CallValues{"userID": "id-like-user-id", "cc": []string{"id-like-user-id"}, "message": "hi"},
+		},
+		"bool": {
+			args:     []string{"--urgent", "--message", "hi"},
+			expected: CallValues{"urgent": "true", "message": "hi"},
+		},
+		"bool value": {
+			args:     []string{"--urgent", "false", "--message", "hi"},
+			expected: CallValues{"urgent": "false", "message": "hi"},
+		},
+		"multi": {
+			args:     []string{"--cc", "@bob", "--cc=bob", "--message", "hi"},
+			expected: CallValues{"cc": []string{"bob-id", "bob-id"}, "message": "hi"},
+		},
+		"number": {
+			args:     []string{"--count", "-3", "--message", "hi"},
+			expected: CallValues{"count": "-3", "message": "hi"},
+		},
+		"number step": {
+			args:                []string{"--count", "1.5", "--message", "hi"},
+			expectedError:       "invalid arguments: count: must be in steps of 1",
+			expectedFieldErrors: map[string]string{"count": "must be in steps of 1"},
+		},
+		"markdown": {
+			args:          []string{"--note", "x"},
+			expectedError: "unknown flag --note",
+		},
+		"unknown flag": {
+			args:          []string{"--team", "x"},
+			expectedError: "unknown flag --team",
+		},
+		"too many": {
+			args:          []string{"a", "b", "c"},
+			expectedError: `unexpected argument "c"`,
+		},
+		"missing value": {
+			args:                []string{"--message"},
+			expectedError:       "invalid arguments: message: --message requires a value",
+			expectedFieldErrors: map[string]string{"message": "--message requires a value"},
+		},
+		"required": {
+			args:                []string{"--user", "@bob"},
+			expectedError:       "invalid arguments: message: is required",
+			expectedFieldErrors: map[string]string{"message": "is required"},
+		},
+		"length": {
+			args:                []string{"title", "ハロー、世界"},
+			expectedError:       "invalid arguments: message: must be at most 5 characters",
+			expectedFieldErrors: map[string]string{"message": "must be at most 5 characters"},
+		},
+		"not found": {
+			args:          []string{"--user", "@alice", "--channel", "~off-topic", "--message", "h"},
+			expectedError: "invalid arguments: channel: channel ~off-topic not found, message: must be at least 2 characters, userID: user @alice not found",
+			expectedFieldErrors: map[string]string{
+				"userID":  "user @alice not found",
+				"channel": "channel ~off-topic not found",
+				"message": "must be at least 2 characters",
+			},
+		},
+		"channel ID of a channel the user is not a member of": {
+			args:                []string{"--channel", "zyxwvutsrqponmlkjihgfedcba", "--message", "hi"},
+			expectedError:       "invalid arguments: channel: channel zyxwvutsrqponmlkjihgfedcba not found",
+			expectedFieldErrors: map[string]string{"channel": "channel zyxwvutsrqponmlkjihgfedcba not found"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			values, err := ParseCommandValues(tc.args, form, testResolver{})
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				if tc.expectedFieldErrors != nil {
+					require.Equal(t, tc.expectedFieldErrors, err.(*CommandError).FieldErrors)
+				}
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, values)
+		})
+	}
+}
@@ -17,7 +17,21 @@ const (
 	APIPath               = "/api/v1"
 	CallPath              = "/call"
 	SubscribePath         = "/subscribe"
+	SubscriptionsPath     = "/subscriptions"
 	BindingsPath          = "/bindings"
+	RefreshBindingsPath   = "/refresh-bindings"
+	LookupPath            = "/lookup"
+
+	// OAuth2Path is the root of the OAuth2 connect flow for the App's users,
+	// OAuth2CompletePath is the redirect URL path, as used by oauther.
+	OAuth2Path         = "/oauth2"
+	OAuth2CompletePath = "/complete"
+
+	// RemoteOAuth2Path is the root of the connect flow for the third-party
+	// OAuth2 providers declared by the Apps, see RemoteOAuth2Provider.
+	RemoteOAuth2Path         = "/remote-oauth2"
+	RemoteOAuth2ConnectPath  = "/connect"
+	RemoteOAuth2CompletePath = "/complete"
 )
 
 // Conventions for Apps paths, and field names
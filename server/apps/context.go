@@ -14,19 +14,24 @@ type Context struct {
 	PostID       string            `json:"post_id,omitempty"`
 	RootPostID   string            `json:"root_post_id,omitempty"`
 	Props        map[string]string `json:"props,omitempty"`
+
 	ExpandedContext
 }
 
 type ExpandedContext struct {
-	ActingUser *model.User       `json:"acting_user,omitempty"`
-	App        *App              `json:"app,omitempty"`
-	Channel    *model.Channel    `json:"channel,omitempty"`
-	Config     *MattermostConfig `json:"config,omitempty"`
-	Mentioned  []*model.User     `json:"mentioned,omitempty"`
-	Post       *model.Post       `json:"post,omitempty"`
-	RootPost   *model.Post       `json:"root_post,omitempty"`
-	Team       *model.Team       `json:"team,omitempty"`
-	User       *model.User       `json:"user,omitempty"`
+	ActingUser            *model.User                   `json:"acting_user,omitempty"`
+	ActingUserAccessToken string                        `json:"acting_user_access_token,omitempty"`
+	App                   *App                          `json:"app,omitempty"`
+	Channel               *model.Channel                `json:"channel,omitempty"`
+	ChannelHistory        []*model.Post                 `json:"channel_history,omitempty"`
+	Config                *MattermostConfig             `json:"config,omitempty"`
+	Mentioned             []*model.User                 `json:"mentioned,omitempty"`
+	Post                  *model.Post                   `json:"post,omitempty"`
+	RemoteOAuth2          map[string]*RemoteOAuth2Token `json:"remote_oauth2,omitempty"`
+	RootPost              *model.Post                   `json:"root_post,omitempty"`
+	Team                  *model.Team                   `json:"team,omitempty"`
+	Thread                []*model.Post                 `json:"thread,omitempty"`
+	User                  *model.User                   `json:"user,omitempty"`
 }
 
 type MattermostConfig struct {
@@ -63,6 +68,15 @@ func NewChannelContext(ch *model.Channel) *Context {
 	}
 }
 
+func NewTeamContext(team *model.Team) *Context {
+	return &Context{
+		TeamID: team.Id,
+		ExpandedContext: ExpandedContext{
+			Team: team,
+		},
+	}
+}
+
 func NewPostContext(p *model.Post) *Context {
 	return &Context{
 		UserID:     p.UserId,
@@ -21,4 +21,25 @@ type Expand struct {
 	RootPost   ExpandLevel `json:"root_post,omitempty"`
 	Team       ExpandLevel `json:"team,omitempty"`
 	User       ExpandLevel `json:"user,omitempty"`
+
+	// Thread and ChannelHistory expand to the most recent posts of the thread
+	// (of RootPostID, or PostID), or of the channel, in chronological order.
+	// The number of posts is limited by the plugin configuration. They are
+	// only expanded when there is an acting user, and the acting user can
+	// read the channel.
+	Thread         ExpandLevel `json:"thread,omitempty"`
+	ChannelHistory ExpandLevel `json:"channel_history,omitempty"`
+
+	// ActingUserAccessToken requests the acting user's OAuth2 access token,
+	// so that the App can use the Mattermost REST API on the user's behalf. It
+	// requires PermissionActAsUser. If the user has not yet connected their
+	// account to the App, the Call responds with the connect URL instead, and
+	// is continued once the user has connected.
+	ActingUserAccessToken ExpandLevel `json:"acting_user_access_token,omitempty"`
+
+	// RemoteOAuth2 requests the acting user's tokens for the third-party
+	// OAuth2 providers declared in the App's manifest. For each provider it
+	// expands to either a valid (refreshed if needed) access token, or the
+	// URL the user needs to visit to connect their account.
+	RemoteOAuth2 ExpandLevel `json:"remote_oauth2,omitempty"`
 }
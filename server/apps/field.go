@@ -1,5 +1,15 @@
 package apps
 
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
 type FieldType string
 
 const (
@@ -9,6 +19,25 @@ const (
 	FieldTypeBool          = FieldType("bool")
 	FieldTypeUser          = FieldType("user")
 	FieldTypeChannel       = FieldType("channel")
+
+	// FieldTypeDate values are dates, as "2006-01-02".
+	FieldTypeDate = FieldType("date")
+	// FieldTypeDateTime values are times in RFC3339, as
+	// "2006-01-02T15:04:05Z07:00".
+	FieldTypeDateTime = FieldType("datetime")
+	// FieldTypeNumber values are decimal numbers, within NumberMin and
+	// NumberMax, and in NumberStep increments.
+	FieldTypeNumber = FieldType("number")
+	// FieldTypeMarkdown is a read-only block showing the markdown in
+	// Description. It has no value.
+	FieldTypeMarkdown = FieldType("markdown")
+	// FieldTypeFile values are the IDs of the files uploaded by the user.
+	FieldTypeFile = FieldType("file")
+)
+
+const (
+	DateFormat     = "2006-01-02"
+	DateTimeFormat = time.RFC3339
 )
 
 type SelectOption struct {
@@ -23,8 +52,9 @@ type Field struct {
 	Type       FieldType `json:"type"`
 	IsRequired bool      `json:"is_required,omitempty"`
 
-	// Present (default) value of the field
-	Value string `json:"value,omitempty"`
+	// Present (default) value of the field, a string, or a list of strings
+	// if SelectIsMulti is set.
+	Value interface{} `json:"value,omitempty"`
 
 	Description string `json:"description,omitempty"`
 
@@ -39,8 +69,144 @@ type Field struct {
 	SelectSourceURL         string         `json:"source_url,omitempty"`
 	SelectStaticOptions     []SelectOption `json:"options,omitempty"`
 
+	// SelectIsMulti allows selecting multiple values in the static and
+	// dynamic select, user and channel fields. The values are lists.
+	SelectIsMulti bool `json:"multiselect,omitempty"`
+
 	// Text props
 	TextSubtype   string `json:"subtype,omitempty"`
 	TextMinLength int    `json:"min_length,omitempty"`
 	TextMaxLength int    `json:"max_length,omitempty"`
+
+	// Number props, the step is counted from NumberMin, or 0.
+	NumberMin  *float64 `json:"min,omitempty"`
+	NumberMax  *float64 `json:"max,omitempty"`
+	NumberStep float64  `json:"step,omitempty"`
+}
+
+// FlagName is the name of the field's --flag in commands, its Label or Name.
+func (f *Field) FlagName() string {
+	if f.Label != "" {
+		return f.Label
+	}
+	return f.Name
+}
+
+// IsMulti returns true if the field's values are lists.
+func (f *Field) IsMulti() bool {
+	if !f.SelectIsMulti {
+		return false
+	}
+	switch f.Type {
+	case FieldTypeStaticSelect, FieldTypeDynamicSelect, FieldTypeUser, FieldTypeChannel:
+		return true
+	}
+	return false
+}
+
+func (f *Field) validateValue(value interface{}) string {
+	if f.Type == FieldTypeMarkdown {
+		if value != nil && value != "" {
+			return "is read-only"
+		}
+		return ""
+	}
+
+	var list []string
+	switch value := value.(type) {
+	case nil:
+	case string:
+		if value != "" {
+			list = []string{value}
+		}
+	case []string:
+		if !f.IsMulti() {
+			return "must be a single value"
+		}
+		list = value
+	default:
+		return "must be a string or a list"
+	}
+	if len(list) == 0 {
+		if f.IsRequired {
+			return "is required"
+		}
+		return ""
+	}
+
+	for _, v := range list {
+		if msg := f.validateOne(v); msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+func (f *Field) validateOne(v string) string {
+	switch f.Type {
+	case FieldTypeText:
+		n := utf8.RuneCountInString(v)
+		switch {
+		case f.TextMinLength > 0 && n < f.TextMinLength:
+			return fmt.Sprintf("must be at least %d characters", f.TextMinLength)
+		case f.TextMaxLength > 0 && n > f.TextMaxLength:
+			return fmt.Sprintf("must be at most %d characters", f.TextMaxLength)
+		}
+
+	case FieldTypeStaticSelect:
+		for _, opt := range f.SelectStaticOptions {
+			if opt.Value == v {
+				return ""
+			}
+		}
+		return "is not one of the options"
+
+	case FieldTypeBool:
+		if v != "true" && v != "false" {
+			return "must be true or false"
+		}
+
+	case FieldTypeDate:
+		if _, err := time.Parse(DateFormat, v); err != nil {
+			return "must be a date, as YYYY-MM-DD"
+		}
+
+	case FieldTypeDateTime:
+		if _, err := time.Parse(DateTimeFormat, v); err != nil {
+			return "must be a time, as YYYY-MM-DDThh:mm:ssZ"
+		}
+
+	case FieldTypeNumber:
+		return f.validateNumber(v)
+
+	case FieldTypeFile:
+		if !model.IsValidId(v) {
+			return "must be a file ID"
+		}
+	}
+	return ""
+}
+
+func (f *Field) validateNumber(v string) string {
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+		return "must be a number"
+	}
+	switch {
+	case f.NumberMin != nil && n < *f.NumberMin:
+		return fmt.Sprintf("must be at least %v", *f.NumberMin)
+	case f.NumberMax != nil && n > *f.NumberMax:
+		return fmt.Sprintf("must be at most %v", *f.NumberMax)
+	}
+	if f.NumberStep > 0 {
+		from := 0.0
+		if f.NumberMin != nil {
+			from = *f.NumberMin
+		}
+		steps := (n - from) / f.NumberStep
+		if math.Abs(steps-math.Round(steps)) > 1e-9 {
+			return fmt.Sprintf("must be in steps of %v", f.NumberStep)
+		}
+	}
+	return ""
 }
@@ -1,5 +1,10 @@
 package apps
 
+import (
+	"encoding/json"
+	"sort"
+)
+
 type Form struct {
 	Title  string `json:"title,omitempty"`
 	Header string `json:"header,omitempty"`
@@ -28,3 +33,112 @@ type Form struct {
 
 	Fields []*Field `json:"fields,omitempty"`
 }
+
+// PositionalFields returns the fields with an AutocompletePosition, in order.
+// The read-only fields are not included.
+func (f *Form) PositionalFields() []*Field {
+	out := []*Field{}
+	for _, field := range f.Fields {
+		if field.AutocompletePosition > 0 && field.Type != FieldTypeMarkdown {
+			out = append(out, field)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].AutocompletePosition < out[j].AutocompletePosition
+	})
+	return out
+}
+
+// ValidateValues checks the values against the fields' constraints, and
+// returns the errors by the field Name.
+func (f *Form) ValidateValues(values CallValues) map[string]string {
+	errs := map[string]string{}
+	for _, field := range f.Fields {
+		if msg := field.validateValue(values[field.Name]); msg != "" {
+			errs[field.Name] = msg
+		}
+	}
+	return errs
+}
+
+// DependsOnChanged returns true if any of the fields that the form DependsOn
+// has a different value in values than in the values it was made for.
+func (f *Form) DependsOnChanged(madeFor, values CallValues) bool {
+	for _, name := range f.DependsOn {
+		if !madeFor.Equal(values, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultValues returns the values of the form's fields, by Field.Value,
+// normalized like the values of a Call.
+func (f *Form) DefaultValues() CallValues {
+	raw := map[string]interface{}{}
+	for _, field := range f.Fields {
+		if field.Value != nil && field.Type != FieldTypeMarkdown {
+			raw[field.Name] = field.Value
+		}
+	}
+	values := CallValues{}
+	data, err := json.Marshal(raw)
+	if err == nil {
+		err = json.Unmarshal(data, &values)
+	}
+	if err != nil {
+		return CallValues{}
+	}
+	return values
+}
+
+// WithValues returns a copy of the form with the values set for its fields.
+// The values of the fields that are not in the form are dropped.
+func (f *Form) WithValues(values CallValues) *Form {
+	out := *f
+	out.Fields = make([]*Field, len(f.Fields))
+	for i, field := range f.Fields {
+		out.Fields[i] = field
+		if v := values[field.Name]; field.Type != FieldTypeMarkdown && len(values.GetList(field.Name)) > 0 {
+			withValue := *field
+			withValue.Value = v
+			out.Fields[i] = &withValue
+		}
+	}
+	return &out
+}
+
+// Update returns a copy of the form patched by a form update. The fields in
+// the update replace the fields with the same Name, or are added. The other
+// properties are replaced if set in the update.
+func (f *Form) Update(update *Form) *Form {
+	out := *f
+	if update.Title != "" {
+		out.Title = update.Title
+	}
+	if update.Header != "" {
+		out.Header = update.Header
+	}
+	if update.Footer != "" {
+		out.Footer = update.Footer
+	}
+	if update.DependsOn != nil {
+		out.DependsOn = update.DependsOn
+	}
+
+	out.Fields = append([]*Field{}, f.Fields...)
+	for _, field := range update.Fields {
+		replaced := false
+		for i := range out.Fields {
+			if out.Fields[i].Name == field.Name {
+				out.Fields[i] = field
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			out.Fields = append(out.Fields, field)
+		}
+	}
+	return &out
+}
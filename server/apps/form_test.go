@@ -0,0 +1,116 @@
+package apps
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateValues(t *testing.T) {
+	form := &Form{
+		Fields: []*Field{
+			{Name: "text", Type: FieldTypeText, TextMinLength: 2},
+			{Name: "bool", Type: FieldTypeBool},
+			{Name: "user", Type: FieldTypeUser, IsRequired: true},
+		},
+	}
+	require.Equal(t, map[string]string{
+		"text": "must be at least 2 characters",
+		"bool": "must be true or false",
+		"user": "is required",
+	}, form.ValidateValues(CallValues{"text": "a", "bool": "yes"}))
+	require.Empty(t, form.ValidateValues(CallValues{"text": "ab", "bool": "false", "user": model.NewId()}))
+}
+
+func TestValidateValue(t *testing.T) {
+	min, max := 1.5, 10.0
+	for name, tc := range map[string]struct {
+		field    *Field
+		value    interface{}
+		expected string
+	}{
+		"date":                {&Field{Type: FieldTypeDate}, "2020-12-31", ""},
+		"date invalid":        {&Field{Type: FieldTypeDate}, "12/31/2020", "must be a date, as YYYY-MM-DD"},
+		"datetime":            {&Field{Type: FieldTypeDateTime}, "2020-12-31T23:59:00-08:00", ""},
+		"datetime invalid":    {&Field{Type: FieldTypeDateTime}, "2020-12-31", "must be a time, as YYYY-MM-DDThh:mm:ssZ"},
+		"number":              {&Field{Type: FieldTypeNumber, NumberMin: &min, NumberMax: &max, NumberStep: 0.5}, "9.5", ""},
+		"number invalid":      {&Field{Type: FieldTypeNumber}, "ten", "must be a number"},
+		"number below min":    {&Field{Type: FieldTypeNumber, NumberMin: &min}, "1", "must be at least 1.5"},
+		"number above max":    {&Field{Type: FieldTypeNumber, NumberMax: &max}, "10.1", "must be at most 10"},
+		"number step":         {&Field{Type: FieldTypeNumber, NumberMin: &min, NumberStep: 1}, "3", "must be in steps of 1"},
+		"markdown":            {&Field{Type: FieldTypeMarkdown, IsRequired: true}, nil, ""},
+		"markdown value":      {&Field{Type: FieldTypeMarkdown}, "x", "is read-only"},
+		"file":                {&Field{Type: FieldTypeFile}, model.NewId(), ""},
+		"file invalid":        {&Field{Type: FieldTypeFile}, "file.txt", "must be a file ID"},
+		"multi":               {&Field{Type: FieldTypeStaticSelect, SelectIsMulti: true, SelectStaticOptions: []SelectOption{{Value: "a"}, {Value: "b"}}}, []string{"a", "b"}, ""},
+		"multi single value":  {&Field{Type: FieldTypeStaticSelect, SelectIsMulti: true, SelectStaticOptions: []SelectOption{{Value: "a"}}}, "a", ""},
+		"multi invalid":       {&Field{Type: FieldTypeStaticSelect, SelectIsMulti: true, SelectStaticOptions: []SelectOption{{Value: "a"}}}, []string{"a", "c"}, "is not one of the options"},
+		"multi required":      {&Field{Type: FieldTypeUser, SelectIsMulti: true, IsRequired: true}, []string{}, "is required"},
+		"list for single":     {&Field{Type: FieldTypeText}, []string{"a"}, "must be a single value"},
+		"list for multi text": {&Field{Type: FieldTypeText, SelectIsMulti: true}, []string{"a"}, "must be a single value"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.field.validateValue(tc.value))
+		})
+	}
+}
+
+func TestFormUpdate(t *testing.T) {
+	form := &Form{
+		Title:     "title",
+		DependsOn: []string{"a"},
+		Fields: []*Field{
+			{Name: "a", Type: FieldTypeText},
+			{Name: "b", Type: FieldTypeText},
+		},
+	}
+
+	updated := form.Update(&Form{
+		Header: "header",
+		Fields: []*Field{
+			{Name: "b", Type: FieldTypeBool},
+			{Name: "c", Type: FieldTypeText},
+		},
+	})
+	require.Equal(t, &Form{
+		Title:     "title",
+		Header:    "header",
+		DependsOn: []string{"a"},
+		Fields: []*Field{
+			{Name: "a", Type: FieldTypeText},
+			{Name: "b", Type: FieldTypeBool},
+			{Name: "c", Type: FieldTypeText},
+		},
+	}, updated)
+	require.Equal(t, FieldTypeText, form.Fields[1].Type)
+
+	withValues := form.WithValues(CallValues{"a": "1", "x": "2"})
+	require.Equal(t, "1", withValues.Fields[0].Value)
+	require.Nil(t, withValues.Fields[1].Value)
+	require.Nil(t, form.Fields[0].Value)
+
+	require.False(t, form.DependsOnChanged(CallValues{"a": "1"}, CallValues{"a": "1", "b": "2"}))
+	require.True(t, form.DependsOnChanged(CallValues{"a": "1"}, CallValues{"a": "2"}))
+	require.True(t, form.DependsOnChanged(nil, CallValues{"a": "1"}))
+}
+
+func TestFormDefaultValues(t *testing.T) {
+	var form Form
+	err := json.Unmarshal([]byte(`{"fields": [
+		{"name": "text", "type": "text", "value": "hi"},
+		{"name": "count", "type": "number", "value": 3},
+		{"name": "urgent", "type": "bool", "value": true},
+		{"name": "cc", "type": "user", "multiselect": true, "value": ["a", "b"]},
+		{"name": "help", "type": "markdown", "value": "**read me**"},
+		{"name": "empty", "type": "text"}
+	]}`), &form)
+	require.NoError(t, err)
+	require.Equal(t, CallValues{
+		"text":   "hi",
+		"count":  "3",
+		"urgent": "true",
+		"cc":     []string{"a", "b"},
+	}, form.DefaultValues())
+}
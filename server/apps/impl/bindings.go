@@ -1,10 +1,15 @@
 package impl
 
 import (
+	"context"
 	"fmt"
+	"time"
 
-	"github.com/mattermost/mattermost-plugin-apps/server/apps"
 	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
 )
 
 func mergeBindings(bb1, bb2 []*apps.Binding) []*apps.Binding {
@@ -42,29 +47,269 @@ func addAppID(bb []*apps.Binding, appID apps.AppID) []*apps.Binding {
 	return bb
 }
 
+// bindingsFetchTimeout is how long to wait for the Apps' bindings. The Apps
+// that do not respond in time are skipped.
+var bindingsFetchTimeout = 3 * time.Second
+
+// GetBindings fetches the bindings of all Apps in parallel. The Apps that fail
+// or time out are logged and skipped, so that they do not hide the bindings of
+// the others. The requests that are still in flight when it returns are
+// cancelled.
+//
 // This and registry related calls should be RPC calls so they can be reused by other plugins
 func (s *service) GetBindings(cc *apps.Context) ([]*apps.Binding, error) {
 	allApps := s.ListApps()
+	client := s.Client
 
+	type result struct {
+		bindings []*apps.Binding
+		err      error
+	}
+
+	// The cache is only used if it is known which entries are invalidated.
+	invalidations, err := s.Store.GetBindingsInvalidations()
+	if err != nil {
+		s.Mattermost.Log.Warn("failed to get the bindings invalidations", "err", err.Error())
+	}
+	useCache := err == nil
+	if useCache {
+		// The clients fetch the bindings on all the nodes, this is how the
+		// invalidations on the other nodes are noticed.
+		s.refreshAppCommands(invalidations)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bindingsFetchTimeout)
+	defer cancel()
+
+	results := make([]chan result, len(allApps))
+	for i, app := range allApps {
+		results[i] = make(chan result, 1)
+		go func(out chan<- result, app *apps.App) {
+			bb, err := s.fetchAppBindings(ctx, client, app, cc, invalidations[app.Manifest.AppID], useCache)
+			out <- result{bindings: bb, err: err}
+		}(results[i], app)
+	}
+
+	roles := s.newRoleChecker(cc)
 	all := []*apps.Binding{}
-	for _, app := range allApps {
-		appCC := *cc
-		appCC.AppID = app.Manifest.AppID
-		bb, err := s.Client.GetBindings(&appCC)
-		if err != nil {
-			return nil, errors.Wrapf(err, "failed to get bindings for %s", app.Manifest.AppID)
+	for i, app := range allApps {
+		// The results that are already in are used even past the deadline.
+		var r result
+		select {
+		case r = <-results[i]:
+		default:
+			select {
+			case r = <-results[i]:
+			case <-ctx.Done():
+				r.err = errors.Errorf("timed out after %v", bindingsFetchTimeout)
+			}
+		}
+		if r.err != nil {
+			s.Mattermost.Log.Warn("failed to get bindings", "app_id", app.Manifest.AppID, "err", r.err.Error())
+			s.bindingsErrors.Store(app.Manifest.AppID, &apps.BindingsError{
+				Error: r.err.Error(),
+				At:    model.GetMillis(),
+			})
+			continue
 		}
+		s.bindingsErrors.Delete(app.Manifest.AppID)
 
-		bb = addAppID(bb, app.Manifest.AppID)
-		all = mergeBindings(all, s.scanAppBindings(app, bb, ""))
+		bb := addAppID(r.bindings, app.Manifest.AppID)
+		all = mergeBindings(all, s.scanAppBindings(app, bb, "", roles))
 	}
 
 	return all, nil
 }
 
+// fetchAppBindings returns the App's bindings for the context, from the cache
+// if useCache is set and they were cached after invalidatedAt. The cache is
+// keyed by the context fields that the bindings depend on, the App is always
+// sent the whole context so that its bindings may start depending on more
+// fields. The request to the App is cancelled with ctx.
+func (s *service) fetchAppBindings(ctx context.Context, client apps.Client, app *apps.App, cc *apps.Context, invalidatedAt int64, useCache bool) ([]*apps.Binding, error) {
+	appID := app.Manifest.AppID
+	appCC := *cc
+	appCC.AppID = appID
+	if useCache {
+		key := bindingsCacheKey(s.getBindingsInfo(appID).deps.apply(&appCC))
+		if bb, ok := s.bindingsCache.get(appID, key, invalidatedAt); ok {
+			return bb, nil
+		}
+	}
+
+	fetchedAt := model.GetMillis()
+	bb, ttl, err := client.GetBindings(ctx, &appCC)
+	if err != nil {
+		return nil, err
+	}
+
+	// The bindings may declare that they depend on fewer context fields than
+	// they were fetched with, so they are reused for all the contexts that only
+	// differ by the other fields.
+	info := s.updateBindingsInfo(appID, bb)
+	if ttl == 0 {
+		ttl = time.Duration(app.Manifest.BindingsTTL) * time.Second
+	}
+	if useCache && ttl > 0 {
+		s.bindingsCache.put(appID, bindingsCacheKey(info.deps.apply(&appCC)), bb, fetchedAt, ttl)
+	}
+	return bb, nil
+}
+
+// fetchContextBindings fetches the App's bindings for a single context, e.g.
+// the context of a Call, from the cache if they have not been invalidated.
+func (s *service) fetchContextBindings(cc *apps.Context) ([]*apps.Binding, error) {
+	app, err := s.GetApp(cc.AppID)
+	if err != nil {
+		return nil, err
+	}
+	invalidations, err := s.Store.GetBindingsInvalidations()
+	useCache := err == nil
+
+	ctx, cancel := context.WithTimeout(context.Background(), bindingsFetchTimeout)
+	defer cancel()
+	return s.fetchAppBindings(ctx, s.Client, app, cc, invalidations[cc.AppID], useCache)
+}
+
+// bindingsInfo is what is learned about an App's bindings when they are
+// fetched.
+type bindingsInfo struct {
+	deps bindingsDependencies
+
+	// callRoles has the RoleIDs that each of the bindings calling a URL
+	// requires, with its ancestors', by URL. A binding that requires no role
+	// has an empty list.
+	callRoles map[string][][]string
+}
+
+func bindingsInfoOf(bindings []*apps.Binding) bindingsInfo {
+	info := bindingsInfo{
+		deps:      bindingsDependenciesOf(bindings),
+		callRoles: map[string][][]string{},
+	}
+	var scan func([]*apps.Binding, []string)
+	scan = func(bindings []*apps.Binding, roleIDs []string) {
+		for _, b := range bindings {
+			bRoleIDs := roleIDs
+			if b.RoleID != "" {
+				bRoleIDs = append(append([]string{}, roleIDs...), b.RoleID)
+			}
+			if b.Call != nil && b.Call.URL != "" {
+				url := b.Call.URL
+				info.callRoles[url] = append(info.callRoles[url], bRoleIDs)
+			}
+			scan(b.Bindings, bRoleIDs)
+		}
+	}
+	scan(bindings, []string{})
+	return info
+}
+
+// updateBindingsInfo stores what is learned from the App's freshly fetched
+// bindings. The Call URLs that are not in them keep the roles learned before, since the bindings fetched for one context may not have the
+// ones that are role-gated in another.
+func (s *service) updateBindingsInfo(appID apps.AppID, bindings []*apps.Binding) bindingsInfo {
+	info := bindingsInfoOf(bindings)
+
+	s.bindingsInfoMutex.Lock()
+	defer s.bindingsInfoMutex.Unlock()
+	prev := s.getBindingsInfo(appID)
+	for url, roleIDs := range prev.callRoles {
+		if _, ok := info.callRoles[url]; !ok {
+			info.callRoles[url] = roleIDs
+		}
+	}
+	s.bindingsInfo.Store(appID, info)
+	return info
+}
+
+// getBindingsInfo returns what was learned when the App's bindings were
+// fetched. Until then, the bindings are assumed to depend on the whole
+// context, and no Call URL is known to be role-gated.
+func (s *service) getBindingsInfo(appID apps.AppID) bindingsInfo {
+	if v, ok := s.bindingsInfo.Load(appID); ok {
+		return v.(bindingsInfo)
+	}
+	return bindingsInfo{
+		deps: allBindingsDependencies,
+	}
+}
+
+// bindingsDependencies are the context fields that an App's bindings depend
+// on, see the DependsOn flags of apps.Binding.
+type bindingsDependencies struct {
+	Team    bool
+	Channel bool
+	User    bool
+	Post    bool
+}
+
+var allBindingsDependencies = bindingsDependencies{
+	Team:    true,
+	Channel: true,
+	User:    true,
+	Post:    true,
+}
+
+// bindingsDependenciesOf collects the DependsOn flags of the bindings and their
+// sub-bindings. Bindings that set none of the flags are assumed to depend on
+// the whole context.
+func bindingsDependenciesOf(bindings []*apps.Binding) bindingsDependencies {
+	deps := bindingsDependencies{}
+	var collect func([]*apps.Binding)
+	collect = func(bindings []*apps.Binding) {
+		for _, b := range bindings {
+			deps.Team = deps.Team || b.DependsOnTeam
+			deps.Channel = deps.Channel || b.DependsOnChannel
+			deps.User = deps.User || b.DependsOnUser
+			deps.Post = deps.Post || b.DependsOnPost
+			collect(b.Bindings)
+		}
+	}
+	collect(bindings)
+	if deps == (bindingsDependencies{}) {
+		return allBindingsDependencies
+	}
+	return deps
+}
+
+// apply returns a copy of the context without the fields that the bindings do
+// not depend on.
+func (d bindingsDependencies) apply(cc *apps.Context) *apps.Context {
+	out := *cc
+	if !d.Team {
+		out.TeamID = ""
+	}
+	if !d.Channel {
+		out.ChannelID = ""
+	}
+	if !d.User {
+		out.ActingUserID = ""
+		out.UserID = ""
+	}
+	if !d.Post {
+		out.PostID = ""
+		out.RootPostID = ""
+	}
+	return &out
+}
+
+// GetBindingsErrors returns the last errors fetching the bindings of the Apps
+// that are currently failing. The errors are kept in memory, so they are only
+// the ones seen by this node of the cluster.
+func (s *service) GetBindingsErrors() map[apps.AppID]*apps.BindingsError {
+	out := map[apps.AppID]*apps.BindingsError{}
+	s.bindingsErrors.Range(func(key, value interface{}) bool {
+		out[key.(apps.AppID)] = value.(*apps.BindingsError)
+		return true
+	})
+	return out
+}
+
 // scanAppBindings removes bindings to locations that have not been granted to
-// the App, and sets the AppID on the relevant elements.
-func (s *service) scanAppBindings(app *apps.App, bindings []*apps.Binding, locPrefix apps.Location) []*apps.Binding {
+// the App, and the ones that require a role the acting user does not have. It
+// sets the AppID on the relevant elements.
+func (s *service) scanAppBindings(app *apps.App, bindings []*apps.Binding, locPrefix apps.Location, roles *roleChecker) []*apps.Binding {
 	out := []*apps.Binding{}
 	for _, appB := range bindings {
 		// clone just in case
@@ -82,13 +327,16 @@ func (s *service) scanAppBindings(app *apps.App, bindings []*apps.Binding, locPr
 			s.Mattermost.Log.Debug(fmt.Sprintf("location %s is not granted to app %s", fql, app.Manifest.AppID))
 			continue
 		}
+		if !roles.has(b.RoleID) {
+			continue
+		}
 
 		if !fql.IsTop() {
 			b.AppID = app.Manifest.AppID
 		}
 
 		if len(b.Bindings) != 0 {
-			scanned := s.scanAppBindings(app, b.Bindings, fql)
+			scanned := s.scanAppBindings(app, b.Bindings, fql, roles)
 			if len(scanned) == 0 {
 				// We do not add bindings without any valid sub-bindings
 				continue
@@ -0,0 +1,145 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package impl
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+)
+
+// bindingsCacheMaxEntries bounds the memory used by the cache. When it is
+// full, the expired entries are dropped, and then arbitrary ones.
+const bindingsCacheMaxEntries = 10000
+
+// WebSocketEventRefreshBindings tells the clients to fetch the bindings again.
+const WebSocketEventRefreshBindings = "refresh_bindings"
+
+type bindingsCacheEntry struct {
+	// data is the JSON of the bindings as the App returned them, so that every
+	// hit gets its own copy to scan and merge.
+	data      []byte
+	fetchedAt int64
+	expiresAt int64
+}
+
+// bindingsCache holds the Apps' bindings by App, and by the shape of the
+// context they were fetched for.
+type bindingsCache struct {
+	mu      sync.Mutex
+	entries map[apps.AppID]map[string]*bindingsCacheEntry
+	size    int
+}
+
+func newBindingsCache() *bindingsCache {
+	return &bindingsCache{
+		entries: map[apps.AppID]map[string]*bindingsCacheEntry{},
+	}
+}
+
+func bindingsCacheKey(cc *apps.Context) string {
+	return strings.Join([]string{cc.TeamID, cc.ChannelID, cc.ActingUserID, cc.PostID}, "|")
+}
+
+// get returns the cached bindings unless they have expired, or were fetched
+// before the App's last invalidation.
+func (c *bindingsCache) get(appID apps.AppID, key string, invalidatedAt int64) ([]*apps.Binding, bool) {
+	c.mu.Lock()
+	entry := c.entries[appID][key]
+	c.mu.Unlock()
+
+	if entry == nil || entry.expiresAt <= model.GetMillis() || entry.fetchedAt <= invalidatedAt {
+		return nil, false
+	}
+	var bindings []*apps.Binding
+	if err := json.Unmarshal(entry.data, &bindings); err != nil {
+		return nil, false
+	}
+	return bindings, true
+}
+
+func (c *bindingsCache) put(appID apps.AppID, key string, bindings []*apps.Binding, fetchedAt int64, ttl time.Duration) {
+	data, err := json.Marshal(bindings)
+	if err != nil {
+		return
+	}
+	entry := &bindingsCacheEntry{
+		data:      data,
+		fetchedAt: fetchedAt,
+		expiresAt: fetchedAt + ttl.Milliseconds(),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.size >= bindingsCacheMaxEntries {
+		c.evict()
+	}
+	appEntries := c.entries[appID]
+	if appEntries == nil {
+		appEntries = map[string]*bindingsCacheEntry{}
+		c.entries[appID] = appEntries
+	}
+	if appEntries[key] == nil {
+		c.size++
+	}
+	appEntries[key] = entry
+}
+
+func (c *bindingsCache) invalidate(appID apps.AppID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size -= len(c.entries[appID])
+	delete(c.entries, appID)
+}
+
+// evict must be called with the lock held.
+func (c *bindingsCache) evict() {
+	now := model.GetMillis()
+	for _, appEntries := range c.entries {
+		for key, entry := range appEntries {
+			if entry.expiresAt <= now {
+				delete(appEntries, key)
+				c.size--
+			}
+		}
+	}
+	for _, appEntries := range c.entries {
+		for key := range appEntries {
+			if c.size < bindingsCacheMaxEntries*9/10 {
+				return
+			}
+			delete(appEntries, key)
+			c.size--
+		}
+	}
+}
+
+// InvalidateBindings drops the App's cached bindings on all nodes, and tells
+// the clients to fetch the bindings again. The App's slash commands are
+// re-registered on this node in the background.
+func (s *service) InvalidateBindings(appID apps.AppID) error {
+	err := s.Store.InvalidateBindings(appID, model.GetMillis())
+	if err != nil {
+		return err
+	}
+	s.bindingsCache.invalidate(appID)
+	s.Mattermost.Frontend.PublishWebSocketEvent(WebSocketEventRefreshBindings,
+		map[string]interface{}{"app_id": string(appID)},
+		&model.WebsocketBroadcast{})
+
+	if app, err := s.GetApp(appID); err == nil {
+		go func() {
+			err := s.registerAppCommands(app)
+			if err != nil {
+				s.Mattermost.Log.Warn("failed to register app commands", "app_id", appID, "err", err.Error())
+			}
+		}()
+	}
+	return nil
+}
@@ -1,11 +1,21 @@
 package impl
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"sync"
 	"testing"
+	"time"
 
-	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
 )
 
 func testBinding(appID apps.AppID, parent apps.Location, n string) []*apps.Binding {
@@ -214,3 +224,513 @@ func TestMergeBindings(t *testing.T) {
 // 		},
 // 	},
 // })
+
+// testBindingsClient serves the bindings of the Apps. The Apps in hang do not
+// respond until the channel is closed or the request is cancelled, and the
+// cancelled requests are recorded in cancelled. The form Calls are answered from forms,
+// by URL, and recorded in formCalls. The other Calls are answered from
+// responses, or OK, and recorded in posted.
+type testBindingsClient struct {
+	apps.Client
+	bindings  map[apps.AppID][]*apps.Binding
+	errs      map[apps.AppID]error
+	hang      map[apps.AppID]chan struct{}
+	ttl       map[apps.AppID]time.Duration
+	forms     map[string]*apps.Form
+	responses map[string]*apps.CallResponse
+
+	mu        sync.Mutex
+	calls     []string
+	cancelled []apps.AppID
+	contexts  []*apps.Context
+	formCalls []*apps.Call
+	posted    []*apps.Call
+}
+
+func (c *testBindingsClient) GetBindings(ctx context.Context, cc *apps.Context) ([]*apps.Binding, time.Duration, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, string(cc.AppID)+":"+cc.ChannelID)
+	c.contexts = append(c.contexts, cc)
+	c.mu.Unlock()
+	if hang := c.hang[cc.AppID]; hang != nil {
+		select {
+		case <-hang:
+		case <-ctx.Done():
+			c.mu.Lock()
+			c.cancelled = append(c.cancelled, cc.AppID)
+			c.mu.Unlock()
+			return nil, 0, ctx.Err()
+		}
+	}
+	return c.bindings[cc.AppID], c.ttl[cc.AppID], c.errs[cc.AppID]
+}
+
+func (c *testBindingsClient) PostCall(call *apps.Call) (*apps.CallResponse, error) {
+	if call.Type == apps.CallTypeForm {
+		c.mu.Lock()
+		c.formCalls = append(c.formCalls, call)
+		c.mu.Unlock()
+		if form := c.forms[call.URL]; form != nil {
+			return &apps.CallResponse{Type: apps.CallResponseTypeForm, Form: form}, nil
+		}
+		return &apps.CallResponse{Type: apps.CallResponseTypeError, Error: "no form"}, nil
+	}
+	c.mu.Lock()
+	c.posted = append(c.posted, call)
+	c.mu.Unlock()
+	if res := c.responses[call.URL]; res != nil {
+		return res, nil
+	}
+	return &apps.CallResponse{Type: apps.CallResponseTypeOK}, nil
+}
+
+// takeCalls returns the sorted calls made since the last time.
+func (c *testBindingsClient) takeCalls() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	calls := c.calls
+	c.calls = nil
+	sort.Strings(calls)
+	return calls
+}
+
+func TestGetBindingsPartial(t *testing.T) {
+	defer func(timeout time.Duration) { bindingsFetchTimeout = timeout }(bindingsFetchTimeout)
+	bindingsFetchTimeout = 100 * time.Millisecond
+
+	newApp := func(appID apps.AppID) *apps.App {
+		return &apps.App{
+			Manifest:         &apps.Manifest{AppID: appID},
+			GrantedLocations: apps.Locations{apps.LocationChannelHeader},
+		}
+	}
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	s := newTestService(mockAPI, newApp("ok1"), newApp("failing"), newApp("ok2"), newApp("hanging"))
+	hang := make(chan struct{})
+	defer close(hang)
+	client := &testBindingsClient{
+		bindings: map[apps.AppID][]*apps.Binding{
+			"ok1":     {{Location: apps.LocationChannelHeader, Label: "ok1"}},
+			"ok2":     {{Location: apps.LocationChannelHeader, Label: "ok2"}},
+			"failing": {{Location: apps.LocationChannelHeader, Label: "failing"}},
+		},
+		errs: map[apps.AppID]error{
+			"failing": errors.New("test error"),
+		},
+		hang: map[apps.AppID]chan struct{}{
+			"hanging": hang,
+		},
+	}
+	s.Client = client
+	mockAPI.On("KVGet", "bindings_inv").Return(nil, nil)
+	mockAPI.On("LogWarn", "failed to get bindings", "app_id", apps.AppID("failing"), "err", "test error").Once()
+	mockAPI.On("LogWarn", "failed to get bindings", "app_id", apps.AppID("hanging"), "err", "timed out after 100ms").Once()
+
+	start := time.Now()
+	bindings, err := s.GetBindings(&apps.Context{ActingUserID: "user-id"})
+	require.NoError(t, err)
+	require.Less(t, int64(time.Since(start)), int64(time.Second))
+
+	labels := []string{}
+	for _, b := range bindings {
+		labels = append(labels, b.Label)
+	}
+	require.ElementsMatch(t, []string{"ok1", "ok2"}, labels)
+
+	bindingsErrors := s.GetBindingsErrors()
+	require.Len(t, bindingsErrors, 2)
+	require.Equal(t, "test error", bindingsErrors["failing"].Error)
+	require.Equal(t, "timed out after 100ms", bindingsErrors["hanging"].Error)
+
+	// The request that timed out is cancelled.
+	require.Eventually(t, func() bool {
+		client.mu.Lock()
+		defer client.mu.Unlock()
+		return len(client.cancelled) == 1 && client.cancelled[0] == "hanging"
+	}, time.Second, 10*time.Millisecond)
+
+	// The errors are cleared once the App responds.
+	s.Client = &testBindingsClient{
+		bindings: client.bindings,
+	}
+	bindings, err = s.GetBindings(&apps.Context{ActingUserID: "user-id"})
+	require.NoError(t, err)
+	require.Len(t, bindings, 3)
+	require.Empty(t, s.GetBindingsErrors())
+}
+
+func TestGetBindingsCache(t *testing.T) {
+	newApp := func(appID apps.AppID, ttl int) *apps.App {
+		return &apps.App{
+			Manifest:         &apps.Manifest{AppID: appID, BindingsTTL: ttl},
+			GrantedLocations: apps.Locations{apps.LocationChannelHeader},
+		}
+	}
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	s := newTestService(mockAPI, newApp("cached", 60), newApp("uncached", 0), newApp("header", 0))
+	client := &testBindingsClient{
+		bindings: map[apps.AppID][]*apps.Binding{
+			"cached":   {{Location: apps.LocationChannelHeader, Label: "cached"}},
+			"uncached": {{Location: apps.LocationChannelHeader, Label: "uncached"}},
+			"header":   {{Location: apps.LocationChannelHeader, Label: "header"}},
+		},
+		ttl: map[apps.AppID]time.Duration{
+			"header": time.Minute,
+		},
+	}
+	s.Client = client
+	getBindings := func(channelID string) {
+		bindings, err := s.GetBindings(&apps.Context{ActingUserID: "user-id", ChannelID: channelID})
+		require.NoError(t, err)
+		require.Len(t, bindings, 3)
+	}
+
+	mockAPI.On("KVGet", "bindings_inv").Return(nil, nil).Times(3)
+	getBindings("channel1")
+	require.Equal(t, []string{"cached:channel1", "header:channel1", "uncached:channel1"}, client.takeCalls())
+	getBindings("channel1")
+	require.Equal(t, []string{"uncached:channel1"}, client.takeCalls())
+	getBindings("channel2")
+	require.Equal(t, []string{"cached:channel2", "header:channel2", "uncached:channel2"}, client.takeCalls())
+
+	// Invalidated on this node.
+	mockAPI.On("KVGet", "bindings_inv").Return(nil, nil).Once()
+	mockAPI.On("KVSetWithOptions", "bindings_inv", mock.Anything, mock.Anything).Return(true, nil).Once()
+	mockAPI.On("PublishWebSocketEvent", WebSocketEventRefreshBindings, map[string]interface{}{"app_id": "cached"}, &model.WebsocketBroadcast{}).Once()
+	require.NoError(t, s.InvalidateBindings("cached"))
+	// The App's commands are registered again in the background, with the
+	// bindings fetched without a user, which are not cached.
+	require.Eventually(t, func() bool {
+		calls := client.takeCalls()
+		return len(calls) == 1 && calls[0] == "cached:"
+	}, time.Second, 10*time.Millisecond)
+	mockAPI.On("KVGet", "bindings_inv").Return(nil, nil).Once()
+	getBindings("channel1")
+	require.Equal(t, []string{"cached:channel1", "uncached:channel1"}, client.takeCalls())
+
+	// Invalidated on another node, the App's commands are registered again on
+	// this node too.
+	invalidations, _ := json.Marshal(map[apps.AppID]int64{"header": model.GetMillis() + 1000})
+	mockAPI.On("KVGet", "bindings_inv").Return(invalidations, nil).Once()
+	getBindings("channel1")
+	calls := client.takeCalls()
+	require.Eventually(t, func() bool {
+		calls = append(calls, client.takeCalls()...)
+		return len(calls) == 3
+	}, time.Second, 10*time.Millisecond)
+	sort.Strings(calls)
+	require.Equal(t, []string{"header:", "header:channel1", "uncached:channel1"}, calls)
+}
+
+func TestBindingsDependenciesOf(t *testing.T) {
+	for name, tc := range map[string]struct {
+		bindings []*apps.Binding
+		expected bindingsDependencies
+	}{
+		"no bindings": {
+			expected: allBindingsDependencies,
+		},
+		"no flags": {
+			bindings: []*apps.Binding{{Location: "1"}, {Location: "2", Bindings: []*apps.Binding{{Location: "3"}}}},
+			expected: allBindingsDependencies,
+		},
+		"team": {
+			bindings: []*apps.Binding{{Location: "1", DependsOnTeam: true}, {Location: "2"}},
+			expected: bindingsDependencies{Team: true},
+		},
+		"merged with sub-bindings": {
+			bindings: []*apps.Binding{
+				{Location: "1", DependsOnChannel: true},
+				{Location: "2", Bindings: []*apps.Binding{
+					{Location: "3", DependsOnUser: true},
+					{Location: "4", DependsOnPost: true},
+				}},
+			},
+			expected: bindingsDependencies{Channel: true, User: true, Post: true},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.expected, bindingsDependenciesOf(tc.bindings))
+		})
+	}
+}
+
+func TestAppendGetContextDependencies(t *testing.T) {
+	cc := &apps.Context{
+		TeamID:       "team-id",
+		ChannelID:    "channel-id",
+		ActingUserID: "user-id",
+		UserID:       "user-id",
+		PostID:       "post-id",
+		RootPostID:   "root-post-id",
+	}
+	for name, tc := range map[string]struct {
+		deps     bindingsDependencies
+		expected string
+	}{
+		"all": {
+			deps:     allBindingsDependencies,
+			expected: "http://app/bindings?acting_user_id=user-id&channel_id=channel-id&post_id=post-id&team_id=team-id",
+		},
+		"none": {
+			expected: "http://app/bindings",
+		},
+		"team": {
+			deps:     bindingsDependencies{Team: true},
+			expected: "http://app/bindings?team_id=team-id",
+		},
+		"channel and user": {
+			deps:     bindingsDependencies{Channel: true, User: true},
+			expected: "http://app/bindings?acting_user_id=user-id&channel_id=channel-id",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.expected, appendGetContext("http://app/bindings", tc.deps.apply(cc)))
+		})
+	}
+	// The original context is not modified.
+	require.Equal(t, "channel-id", cc.ChannelID)
+}
+
+func TestGetBindingsCacheDependsOn(t *testing.T) {
+	newApp := func(appID apps.AppID) *apps.App {
+		return &apps.App{
+			Manifest:         &apps.Manifest{AppID: appID, BindingsTTL: 60},
+			GrantedLocations: apps.Locations{apps.LocationChannelHeader},
+		}
+	}
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	s := newTestService(mockAPI, newApp("team-app"), newApp("legacy-app"))
+	client := &testBindingsClient{
+		bindings: map[apps.AppID][]*apps.Binding{
+			"team-app":   {{Location: apps.LocationChannelHeader, Label: "team", DependsOnTeam: true}},
+			"legacy-app": {{Location: apps.LocationChannelHeader, Label: "legacy"}},
+		},
+	}
+	s.Client = client
+	mockAPI.On("KVGet", "bindings_inv").Return(nil, nil)
+	getBindings := func(teamID, channelID string) {
+		bindings, err := s.GetBindings(&apps.Context{ActingUserID: "user-id", TeamID: teamID, ChannelID: channelID})
+		require.NoError(t, err)
+		require.Len(t, bindings, 2)
+	}
+
+	// The first fetch has the whole context.
+	getBindings("team1", "channel1")
+	require.Equal(t, []string{"legacy-app:channel1", "team-app:channel1"}, client.takeCalls())
+
+	// Switching channels only refetches the bindings that depend on the
+	// channel.
+	getBindings("team1", "channel2")
+	require.Equal(t, []string{"legacy-app:channel2"}, client.takeCalls())
+
+	// The bindings that depend on the team are refetched when it changes, with
+	// the whole context.
+	client.contexts = nil
+	getBindings("team2", "channel3")
+	require.Equal(t, []string{"legacy-app:channel3", "team-app:channel3"}, client.takeCalls())
+	for _, cc := range client.contexts {
+		if cc.AppID == "team-app" {
+			require.Equal(t, &apps.Context{AppID: "team-app", ActingUserID: "user-id", TeamID: "team2", ChannelID: "channel3"}, cc)
+		}
+	}
+
+	// The bindings may start depending on more fields, they are then cached
+	// by those too.
+	client.bindings["team-app"] = []*apps.Binding{{Location: apps.LocationChannelHeader, Label: "team", DependsOnTeam: true, DependsOnChannel: true}}
+	getBindings("team3", "channel4")
+	require.Equal(t, []string{"legacy-app:channel4", "team-app:channel4"}, client.takeCalls())
+	getBindings("team3", "channel5")
+	require.Equal(t, []string{"legacy-app:channel5", "team-app:channel5"}, client.takeCalls())
+}
+
+func TestGetBindingsRoles(t *testing.T) {
+	app := &apps.App{
+		Manifest:         &apps.Manifest{AppID: "app"},
+		GrantedLocations: apps.Locations{apps.LocationChannelHeader, apps.LocationCommand},
+	}
+	bindings := []*apps.Binding{
+		{
+			Location: apps.LocationChannelHeader,
+			Bindings: []*apps.Binding{
+				{Location: "everyone", Label: "everyone"},
+				{Location: "channel-admin", Label: "channel-admin", RoleID: model.CHANNEL_ADMIN_ROLE_ID},
+				{Location: "system-admin", Label: "system-admin", RoleID: model.SYSTEM_ADMIN_ROLE_ID},
+			},
+		},
+		{
+			Location: apps.LocationCommand,
+			RoleID:   model.TEAM_ADMIN_ROLE_ID,
+			Bindings: []*apps.Binding{
+				{Location: "team-admin", Label: "team-admin"},
+			},
+		},
+	}
+
+	for name, tc := range map[string]struct {
+		userRoles    string
+		teamMember   *model.TeamMember
+		channelAdmin bool
+		expected     []string
+	}{
+		"user": {
+			userRoles:  model.SYSTEM_USER_ROLE_ID,
+			teamMember: &model.TeamMember{SchemeUser: true},
+			expected:   []string{"everyone"},
+		},
+		"channel admin": {
+			userRoles:    model.SYSTEM_USER_ROLE_ID,
+			teamMember:   &model.TeamMember{SchemeUser: true},
+			channelAdmin: true,
+			expected:     []string{"everyone", "channel-admin"},
+		},
+		"team admin": {
+			userRoles:  model.SYSTEM_USER_ROLE_ID,
+			teamMember: &model.TeamMember{SchemeUser: true, SchemeAdmin: true},
+			expected:   []string{"everyone", "channel-admin", "team-admin"},
+		},
+		"system admin": {
+			userRoles:  model.SYSTEM_USER_ROLE_ID + " " + model.SYSTEM_ADMIN_ROLE_ID,
+			teamMember: &model.TeamMember{SchemeUser: true},
+			expected:   []string{"everyone", "channel-admin", "system-admin", "team-admin"},
+		},
+		"team member not found": {
+			userRoles: model.SYSTEM_USER_ROLE_ID,
+			expected:  []string{"everyone"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mockAPI := &plugintest.API{}
+			defer mockAPI.AssertExpectations(t)
+			s := newTestService(mockAPI, app)
+			s.Client = &testBindingsClient{
+				bindings: map[apps.AppID][]*apps.Binding{"app": bindings},
+			}
+			mockAPI.On("KVGet", "bindings_inv").Return(nil, nil)
+			mockAPI.On("GetUser", "user-id").Return(&model.User{Id: "user-id", Roles: tc.userRoles}, nil).Once()
+			if tc.teamMember != nil {
+				mockAPI.On("GetTeamMember", "team-id", "user-id").Return(tc.teamMember, nil).Once()
+			} else {
+				mockAPI.On("GetTeamMember", "team-id", "user-id").Return(nil, &model.AppError{Message: "not found"}).Once()
+			}
+			mockAPI.On("GetChannelMember", "channel-id", "user-id").Return(&model.ChannelMember{SchemeUser: true, SchemeAdmin: tc.channelAdmin}, nil).Once()
+
+			out, err := s.GetBindings(&apps.Context{ActingUserID: "user-id", TeamID: "team-id", ChannelID: "channel-id"})
+			require.NoError(t, err)
+
+			labels := []string{}
+			for _, b := range out {
+				for _, sub := range b.Bindings {
+					labels = append(labels, sub.Label)
+				}
+			}
+			require.Equal(t, tc.expected, labels)
+		})
+	}
+}
+
+func TestCallRoles(t *testing.T) {
+	app := &apps.App{
+		Manifest:         &apps.Manifest{AppID: "app"},
+		GrantedLocations: apps.Locations{apps.LocationChannelHeader},
+	}
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	s := newTestService(mockAPI, app)
+	client := &testBindingsClient{
+		bindings: map[apps.AppID][]*apps.Binding{
+			"app": {
+				{
+					Location: apps.LocationChannelHeader,
+					Bindings: []*apps.Binding{
+						{Location: "open", Call: &apps.Call{URL: "/open"}},
+						{Location: "admin", Call: &apps.Call{URL: "/admin"}, RoleID: model.SYSTEM_ADMIN_ROLE_ID},
+						{
+							Location: "admin-menu",
+							RoleID:   model.SYSTEM_ADMIN_ROLE_ID,
+							Bindings: []*apps.Binding{
+								{Location: "nested", Call: &apps.Call{URL: "/nested"}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	s.Client = client
+	mockAPI.On("KVGet", "bindings_inv").Return(nil, nil)
+	mockAPI.On("GetUser", "user-id").Return(&model.User{Id: "user-id", Roles: model.SYSTEM_USER_ROLE_ID}, nil)
+
+	call := func(url string) error {
+		_, err := s.Call(&apps.Call{
+			URL:     url,
+			Context: &apps.Context{AppID: "app", ActingUserID: "user-id"},
+		})
+		return err
+	}
+	_, err := s.GetBindings(&apps.Context{ActingUserID: "user-id"})
+	require.NoError(t, err)
+	client.takeCalls()
+
+	// The Calls are checked without fetching the bindings.
+	require.EqualError(t, call("/admin"), "/admin is not allowed for the user's roles")
+	require.EqualError(t, call("/nested"), "/nested is not allowed for the user's roles")
+	require.Empty(t, client.takeCalls())
+	require.NoError(t, call("/open"))
+	require.NoError(t, call("/unbound"))
+
+	// The roles are kept for the URLs that the later bindings do not have,
+	// and updated for the ones they have.
+	client.bindings["app"] = []*apps.Binding{
+		{Location: apps.LocationChannelHeader, Call: &apps.Call{URL: "/admin"}},
+	}
+	_, err = s.GetBindings(&apps.Context{ActingUserID: "user-id"})
+	require.NoError(t, err)
+	require.NoError(t, call("/admin"))
+	require.EqualError(t, call("/nested"), "/nested is not allowed for the user's roles")
+}
+
+func TestCallRolesNotFetched(t *testing.T) {
+	app := &apps.App{
+		Manifest:         &apps.Manifest{AppID: "app"},
+		GrantedLocations: apps.Locations{apps.LocationChannelHeader},
+	}
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	s := newTestService(mockAPI, app)
+	client := &testBindingsClient{
+		bindings: map[apps.AppID][]*apps.Binding{
+			"app": {
+				{Location: apps.LocationChannelHeader, Call: &apps.Call{URL: "/admin"}, RoleID: model.SYSTEM_ADMIN_ROLE_ID},
+			},
+		},
+		errs: map[apps.AppID]error{},
+	}
+	s.Client = client
+	mockAPI.On("KVGet", "bindings_inv").Return(nil, nil)
+	mockAPI.On("GetUser", "user-id").Return(&model.User{Id: "user-id", Roles: model.SYSTEM_USER_ROLE_ID}, nil)
+
+	call := func(url string) error {
+		_, err := s.Call(&apps.Call{
+			URL:     url,
+			Context: &apps.Context{AppID: "app", ActingUserID: "user-id"},
+		})
+		return err
+	}
+
+	// The Calls are rejected if the bindings can not be fetched to check them.
+	client.errs["app"] = errors.New("app is down")
+	require.EqualError(t, call("/admin"), "failed to get the bindings to check the call: app is down")
+	client.takeCalls()
+
+	// The bindings are fetched for the Call's context, if this node has not
+	// fetched them yet.
+	delete(client.errs, "app")
+	require.EqualError(t, call("/admin"), "/admin is not allowed for the user's roles")
+	require.Equal(t, []string{"app:"}, client.takeCalls())
+	require.EqualError(t, call("/admin"), "/admin is not allowed for the user's roles")
+	require.Empty(t, client.takeCalls())
+}
@@ -4,11 +4,13 @@
 package impl
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
@@ -97,14 +99,14 @@ func (c *client) post(toApp *apps.App, fromMattermostUserID string, url string,
 	return resp, nil
 }
 
-func (c *client) get(toApp *apps.App, fromMattermostUserID string, url string) (*http.Response, error) {
+func (c *client) get(ctx context.Context, toApp *apps.App, fromMattermostUserID string, url string) (*http.Response, error) {
 	client := c.getClient(toApp.Manifest.AppID)
 	jwtoken, err := createJWT(fromMattermostUserID, toApp.Secret)
 	if err != nil {
 		return nil, errors.Wrap(err, "error creating token")
 	}
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, errors.Wrap(err, "error creating request")
 	}
@@ -149,27 +151,32 @@ func (c *client) GetManifest(manifestURL string) (*apps.Manifest, error) {
 	return &manifest, nil
 }
 
-func (c *client) GetBindings(cc *apps.Context) ([]*apps.Binding, error) {
+func (c *client) GetBindings(ctx context.Context, cc *apps.Context) ([]*apps.Binding, time.Duration, error) {
 	app, err := c.s.GetApp(cc.AppID)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get app")
+		return nil, 0, errors.Wrap(err, "failed to get app")
 	}
 
-	resp, err := c.get(app, cc.ActingUserID, appendGetContext(app.Manifest.RootURL+apps.AppBindingsPath, cc))
+	resp, err := c.get(ctx, app, cc.ActingUserID, appendGetContext(app.Manifest.RootURL+apps.AppBindingsPath, cc))
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get bindings")
+		return nil, 0, errors.Wrap(err, "failed to get bindings")
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("returned with status %s", resp.Status)
+		return nil, 0, fmt.Errorf("returned with status %s", resp.Status)
 	}
 
 	out := []*apps.Binding{}
 	err = json.NewDecoder(resp.Body).Decode(&out)
 	if err != nil {
-		return nil, errors.Wrap(err, "error unmarshalling function")
+		return nil, 0, errors.Wrap(err, "error unmarshalling function")
 	}
-	return out, nil
+
+	var ttl time.Duration
+	if seconds, err := strconv.Atoi(resp.Header.Get(apps.BindingsTTLHeader)); err == nil && seconds > 0 {
+		ttl = time.Duration(seconds) * time.Second
+	}
+	return out, ttl, nil
 }
 
 func appendGetContext(inURL string, cc *apps.Context) string {
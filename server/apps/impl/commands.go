@@ -0,0 +1,488 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package impl
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	pluginapi "github.com/mattermost/mattermost-plugin-api"
+	"github.com/mattermost/mattermost-server/v5/model"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+	"github.com/mattermost/mattermost-plugin-apps/server/utils"
+)
+
+// commandRegistry has the slash commands registered for the Apps' top-level
+// /command bindings. The registrations are local to the node, every node
+// registers the commands when the plugin is activated, and again when it sees
+// that the App's bindings were invalidated since, see refreshAppCommands.
+type commandRegistry struct {
+	mu       sync.Mutex
+	triggers map[string]apps.AppID
+
+	// registeredAt has when the Apps' commands were last registered, or
+	// scheduled to be, by AppID.
+	registeredAt map[apps.AppID]int64
+}
+
+func newCommandRegistry() *commandRegistry {
+	return &commandRegistry{
+		triggers:     map[string]apps.AppID{},
+		registeredAt: map[apps.AppID]int64{},
+	}
+}
+
+// markRegistered records that the App's commands are registered as of at. It
+// returns false if they already were.
+func (r *commandRegistry) markRegistered(appID apps.AppID, at int64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.registeredAt[appID] >= at {
+		return false
+	}
+	r.registeredAt[appID] = at
+	return true
+}
+
+func (r *commandRegistry) appID(trigger string) (apps.AppID, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	appID, ok := r.triggers[trigger]
+	return appID, ok
+}
+
+// RegisterCommands registers the slash commands of all installed Apps.
+func (s *service) RegisterCommands() {
+	for _, app := range s.ListApps() {
+		err := s.registerAppCommands(app)
+		if err != nil {
+			s.Mattermost.Log.Warn("failed to register app commands", "app_id", app.Manifest.AppID, "err", err.Error())
+		}
+	}
+}
+
+// registerAppCommands registers a slash command for each of the App's
+// top-level /command bindings, and unregisters the ones it no longer has. The
+// bindings are fetched without a user, so the commands are the same for all
+// users, the roles are checked when the commands are executed. They are
+// fetched even if the App has no commands, so that its role-gated Call URLs
+// are known before a user fetches the bindings, see checkCallRoles.
+func (s *service) registerAppCommands(app *apps.App) error {
+	appID := app.Manifest.AppID
+	s.commands.markRegistered(appID, model.GetMillis())
+	cc := &apps.Context{AppID: appID}
+	bindings, err := s.fetchAppBindings(context.Background(), s.Client, app, cc, 0, false)
+	if err != nil {
+		return errors.Wrap(err, "failed to get bindings")
+	}
+
+	commands := map[string]*model.Command{}
+	if isCommandGranted(app) {
+		bindings = s.scanAppBindings(app, addAppID(bindings, appID), "", nil)
+
+		for _, b := range commandBindings(bindings) {
+			trigger := commandTrigger(b)
+			if trigger == "" || trigger == apps.CommandTrigger || strings.ContainsAny(trigger, " \t/") {
+				s.Mattermost.Log.Debug("invalid app command trigger", "app_id", appID, "trigger", trigger)
+				continue
+			}
+
+			ad := newCommandAutocompleteData(b, trigger)
+			if err = ad.IsValid(); err != nil {
+				s.Mattermost.Log.Debug("invalid app command autocomplete data", "app_id", appID, "trigger", trigger, "err", err.Error())
+				ad = nil
+			}
+			commands[trigger] = &model.Command{
+				Trigger:          trigger,
+				DisplayName:      app.Manifest.DisplayName,
+				Description:      b.Description,
+				AutoComplete:     true,
+				AutoCompleteDesc: b.Description,
+				AutoCompleteHint: b.Hint,
+				AutocompleteData: ad,
+			}
+		}
+	}
+
+	s.updateAppCommands(appID, commands)
+	return nil
+}
+
+// updateAppCommands registers the App's commands by trigger, and unregisters
+// the App's other commands. The commands that fail to be registered or
+// unregistered are logged, and do not hold up the others.
+func (s *service) updateAppCommands(appID apps.AppID, commands map[string]*model.Command) {
+	r := s.commands
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for trigger, registeredAppID := range r.triggers {
+		if registeredAppID != appID || commands[trigger] != nil {
+			continue
+		}
+		err := s.Mattermost.SlashCommand.Unregister("", trigger)
+		if err != nil {
+			s.Mattermost.Log.Warn("failed to unregister app command", "app_id", appID, "trigger", trigger, "err", err.Error())
+			continue
+		}
+		delete(r.triggers, trigger)
+	}
+	for trigger, command := range commands {
+		if registeredAppID, ok := r.triggers[trigger]; ok && registeredAppID != appID {
+			s.Mattermost.Log.Warn("app command is already registered by another app", "app_id", appID, "trigger", trigger, "registered_by", registeredAppID)
+			continue
+		}
+		err := s.Mattermost.SlashCommand.Register(command)
+		if err != nil {
+			s.Mattermost.Log.Warn("failed to register app command", "app_id", appID, "trigger", trigger, "err", err.Error())
+			continue
+		}
+		r.triggers[trigger] = appID
+	}
+}
+
+// refreshAppCommands registers again, in the background, the commands of the
+// Apps whose bindings were invalidated since they were registered on this
+// node. The invalidations may come from another node of the cluster.
+func (s *service) refreshAppCommands(invalidations map[apps.AppID]int64) {
+	for appID, at := range invalidations {
+		if !s.commands.markRegistered(appID, at) {
+			continue
+		}
+		app, err := s.GetApp(appID)
+		if err != nil {
+			continue
+		}
+		go func() {
+			err := s.registerAppCommands(app)
+			if err != nil {
+				s.Mattermost.Log.Warn("failed to register app commands", "app_id", app.Manifest.AppID, "err", err.Error())
+			}
+		}()
+	}
+}
+
+func isCommandGranted(app *apps.App) bool {
+	for _, loc := range app.GrantedLocations {
+		if loc.In(apps.LocationCommand) {
+			return true
+		}
+	}
+	return false
+}
+
+// commandBindings returns the top-level command bindings, i.e. the
+// sub-bindings of /command.
+func commandBindings(bindings []*apps.Binding) []*apps.Binding {
+	out := []*apps.Binding{}
+	for _, b := range bindings {
+		if b.Location == apps.LocationCommand {
+			out = append(out, b.Bindings...)
+		}
+	}
+	return out
+}
+
+// commandTrigger is the (sub-)command word for a binding, its Label or
+// Location.
+func commandTrigger(b *apps.Binding) string {
+	if b.Label != "" {
+		return strings.ToLower(b.Label)
+	}
+	return strings.ToLower(strings.TrimPrefix(string(b.Location), "/"))
+}
+
+// newCommandAutocompleteData makes the autocomplete data for the binding and
+// its sub-bindings. The arguments are only known for the bindings that embed
+// their Form, the others are not sent a form Call, since there is no acting
+// user when the commands are registered.
+func newCommandAutocompleteData(b *apps.Binding, trigger string) *model.AutocompleteData {
+	ad := model.NewAutocompleteData(trigger, b.Hint, b.Description)
+	// Only the system roles are supported by the autocomplete, the others
+	// are checked when the command is executed.
+	if b.RoleID == model.SYSTEM_ADMIN_ROLE_ID {
+		ad.RoleID = b.RoleID
+	}
+
+	if len(b.Bindings) > 0 {
+		for _, sub := range b.Bindings {
+			ad.AddCommand(newCommandAutocompleteData(sub, commandTrigger(sub)))
+		}
+		return ad
+	}
+
+	addAutocompleteArguments(ad, b.Form)
+	return ad
+}
+
+// addAutocompleteArguments adds the form's fields as the command arguments.
+// The required fields with an AutocompletePosition are positional, the others
+// are named, since the autocomplete has no optional positional arguments.
+func addAutocompleteArguments(ad *model.AutocompleteData, form *apps.Form) {
+	if form == nil {
+		return
+	}
+
+	named := []*apps.Field{}
+	for _, f := range form.PositionalFields() {
+		if !f.IsRequired {
+			named = append(named, f)
+			continue
+		}
+		if items := autocompleteListItems(f); items != nil {
+			ad.AddStaticListArgument(f.Description, true, items)
+		} else {
+			ad.AddTextArgument(f.Description, autocompleteHint(f), "")
+		}
+	}
+	for _, f := range form.Fields {
+		if f.AutocompletePosition <= 0 && f.Type != apps.FieldTypeMarkdown {
+			named = append(named, f)
+		}
+	}
+
+	for _, f := range named {
+		if items := autocompleteListItems(f); items != nil {
+			ad.AddNamedStaticListArgument(f.FlagName(), f.Description, f.IsRequired, items)
+		} else {
+			ad.AddNamedTextArgument(f.FlagName(), f.Description, autocompleteHint(f), "", f.IsRequired)
+		}
+	}
+}
+
+// autocompleteHint is the field's AutocompleteHint, or the format of its
+// values if it has none.
+func autocompleteHint(f *apps.Field) string {
+	if f.AutocompleteHint != "" {
+		return f.AutocompleteHint
+	}
+	switch f.Type {
+	case apps.FieldTypeDate:
+		return "YYYY-MM-DD"
+	case apps.FieldTypeDateTime:
+		return "YYYY-MM-DDThh:mm:ssZ"
+	case apps.FieldTypeNumber:
+		return "number"
+	case apps.FieldTypeFile:
+		return "file ID"
+	}
+	return ""
+}
+
+func autocompleteListItems(f *apps.Field) []model.AutocompleteListItem {
+	switch f.Type {
+	case apps.FieldTypeBool:
+		return []model.AutocompleteListItem{{Item: "true"}, {Item: "false"}}
+
+	case apps.FieldTypeStaticSelect:
+		items := []model.AutocompleteListItem{}
+		for _, opt := range f.SelectStaticOptions {
+			if opt.Value != "" {
+				items = append(items, model.AutocompleteListItem{Item: opt.Value, Hint: opt.Label})
+			}
+		}
+		return items
+	}
+	return nil
+}
+
+// getCommandForm returns the form embedded in the binding, or fetches it with
+// a form Call for the values. It returns nil if the App does not have a form
+// for the Call.
+func getCommandForm(b *apps.Binding, cc *apps.Context, values apps.CallValues, call func(*apps.Call) (*apps.CallResponse, error)) (*apps.Form, error) {
+	if b.Form != nil {
+		return b.Form, nil
+	}
+
+	formCall := *b.Call
+	formCall.Type = apps.CallTypeForm
+	formCall.Context = cc
+	formCall.Values = values
+	res, err := call(&formCall)
+	if err != nil {
+		return nil, err
+	}
+	if res.Type != apps.CallResponseTypeForm {
+		return nil, nil
+	}
+	return res.Form, nil
+}
+
+// ExecuteCommand executes an App slash command. The binding is looked up
+// by the (sub-)command words, and the rest of the command is parsed into the
+// Values of the Call, according to its form. The arguments that do not fit the
+// form are returned as an *apps.CommandError.
+func (s *service) ExecuteCommand(cc *apps.Context, command string) (*apps.CallResponse, error) {
+	words, err := apps.SplitCommand(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 0 || !strings.HasPrefix(words[0], "/") {
+		return nil, errors.Errorf("invalid command %q", command)
+	}
+	trigger := strings.ToLower(strings.TrimPrefix(words[0], "/"))
+	appID, ok := s.commands.appID(trigger)
+	if !ok {
+		return nil, errors.Wrapf(utils.ErrNotFound, "/%s is not an app command", trigger)
+	}
+	app, err := s.GetApp(appID)
+	if err != nil {
+		return nil, err
+	}
+
+	invalidations, err := s.Store.GetBindingsInvalidations()
+	useCache := err == nil
+	bindings, err := s.fetchAppBindings(context.Background(), s.Client, app, cc, invalidations[appID], useCache)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get bindings")
+	}
+	bindings = s.scanAppBindings(app, addAppID(bindings, appID), "", s.newRoleChecker(cc))
+
+	words[0] = trigger
+	b, args := findCommandBinding(commandBindings(bindings), words)
+	if b == nil {
+		return nil, errors.Wrapf(utils.ErrNotFound, "/%s", trigger)
+	}
+	if b.Call == nil {
+		subs := []string{}
+		for _, sub := range b.Bindings {
+			subs = append(subs, commandTrigger(sub))
+		}
+		if len(args) > 0 {
+			return nil, errors.Errorf("unknown subcommand %q, expected one of: %s", args[0], strings.Join(subs, ", "))
+		}
+		return nil, errors.Errorf("expected a subcommand: %s", strings.Join(subs, ", "))
+	}
+
+	callCC := *cc
+	callCC.AppID = appID
+	values, err := s.parseCommandArgs(b, &callCC, args)
+	if err != nil {
+		return nil, err
+	}
+
+	call := *b.Call
+	call.Type = apps.CallTypeSubmit
+	call.Context = &callCC
+	call.RawCommand = command
+	call.Values = values
+	return s.API.Call(&call)
+}
+
+// parseCommandArgs returns the values for the command's Call, the binding's
+// own values and the ones parsed from the arguments. If the arguments change
+// the fields the form DependsOn, the form is fetched again for them and the
+// arguments are parsed against it. Without a form, the App is expected to
+// parse the RawCommand itself.
+func (s *service) parseCommandArgs(b *apps.Binding, cc *apps.Context, args []string) (apps.CallValues, error) {
+	values := apps.CallValues{}
+	for k, v := range b.Call.Values {
+		values[k] = v
+	}
+	resolver := &commandResolver{
+		mm:           s.Mattermost,
+		teamID:       cc.TeamID,
+		actingUserID: cc.ActingUserID,
+	}
+
+	form, err := getCommandForm(b, cc, b.Call.Values, s.API.Call)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the command form")
+	}
+	if form == nil {
+		return values, nil
+	}
+	parsed, err := apps.ParseCommandValues(args, form, resolver)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range parsed {
+		values[k] = v
+	}
+
+	if b.Form == nil && form.DependsOnChanged(b.Call.Values, values) {
+		form, err = getCommandForm(b, cc, values, s.API.Call)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to refresh the command form")
+		}
+		if form != nil {
+			parsed, err = apps.ParseCommandValues(args, form, resolver)
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range parsed {
+				values[k] = v
+			}
+		}
+	}
+	return values, nil
+}
+
+// findCommandBinding follows the (sub-)command words down the bindings, and
+// returns the last binding that matched, and the remaining words.
+func findCommandBinding(bindings []*apps.Binding, words []string) (*apps.Binding, []string) {
+	var found *apps.Binding
+	for len(words) > 0 {
+		var next *apps.Binding
+		for _, b := range bindings {
+			if commandTrigger(b) == strings.ToLower(words[0]) {
+				next = b
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		found, bindings, words = next, next.Bindings, words[1:]
+	}
+	return found, words
+}
+
+// commandResolver resolves the user and channel references in the command
+// arguments, the channels by name in the team of the command. The channels
+// are only resolved if the acting user is a member, so that the plugin's
+// privileges do not reveal the other channels.
+type commandResolver struct {
+	mm           *pluginapi.Client
+	teamID       string
+	actingUserID string
+}
+
+func (r *commandResolver) ResolveUser(username string) (string, error) {
+	user, err := r.mm.User.GetByUsername(username)
+	if err != nil {
+		return "", err
+	}
+	return user.Id, nil
+}
+
+func (r *commandResolver) ResolveUserID(userID string) (string, error) {
+	user, err := r.mm.User.Get(userID)
+	if err != nil {
+		return "", err
+	}
+	return user.Id, nil
+}
+
+func (r *commandResolver) ResolveChannel(name string) (string, error) {
+	if r.teamID == "" {
+		return "", errors.New("no team to look up the channel in")
+	}
+	ch, err := r.mm.Channel.GetByName(r.teamID, name, false)
+	if err != nil {
+		return "", err
+	}
+	return r.ResolveChannelID(ch.Id)
+}
+
+func (r *commandResolver) ResolveChannelID(channelID string) (string, error) {
+	cm, err := r.mm.Channel.GetMember(channelID, r.actingUserID)
+	if err != nil {
+		return "", err
+	}
+	return cm.ChannelId, nil
+}
@@ -0,0 +1,314 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package impl
+
+import (
+	"testing"
+
+	pluginapi "github.com/mattermost/mattermost-plugin-api"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+)
+
+var testCommandForm = &apps.Form{
+	Fields: []*apps.Field{
+		{
+			Name:  "userID",
+			Type:  apps.FieldTypeUser,
+			Label: "user",
+		}, {
+			Name:                 "message",
+			Type:                 apps.FieldTypeText,
+			Label:                "message",
+			IsRequired:           true,
+			TextMinLength:        2,
+			AutocompletePosition: 1,
+		}, {
+			Name:  "urgent",
+			Type:  apps.FieldTypeBool,
+			Label: "urgent",
+		},
+	},
+}
+
+func testCommandBindings(triggers ...string) []*apps.Binding {
+	commands := []*apps.Binding{}
+	for _, trigger := range triggers {
+		commands = append(commands, &apps.Binding{
+			Label:       trigger,
+			Location:    apps.Location(trigger),
+			Description: trigger + " commands",
+			Bindings: []*apps.Binding{
+				{
+					Label: "message",
+					Call:  &apps.Call{URL: "/message", Values: apps.CallValues{"mode": "dm"}},
+				}, {
+					Label:  "admin",
+					RoleID: model.SYSTEM_ADMIN_ROLE_ID,
+					Call:   &apps.Call{URL: "/admin"},
+				},
+			},
+		})
+	}
+	return []*apps.Binding{
+		{
+			Location: apps.LocationCommand,
+			Bindings: commands,
+		},
+	}
+}
+
+func TestRegisterAppCommands(t *testing.T) {
+	newApp := func(appID apps.AppID) *apps.App {
+		return &apps.App{
+			Manifest:         &apps.Manifest{AppID: appID, DisplayName: string(appID)},
+			GrantedLocations: apps.Locations{apps.LocationCommand},
+		}
+	}
+	app1, app2 := newApp("app1"), newApp("app2")
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	s := newTestService(mockAPI, app1, app2)
+	// The arguments are only made for the embedded forms.
+	app1Bindings := testCommandBindings("hello", apps.CommandTrigger)
+	app1Bindings[0].Bindings[0].Bindings[0].Form = testCommandForm
+	client := &testBindingsClient{
+		bindings: map[apps.AppID][]*apps.Binding{
+			"app1": app1Bindings,
+			"app2": testCommandBindings("other"),
+		},
+		forms: map[string]*apps.Form{
+			"/message": testCommandForm,
+		},
+	}
+	s.Client = client
+
+	var registered *model.Command
+	mockAPI.On("LogDebug", "invalid app command trigger", "app_id", apps.AppID("app1"), "trigger", apps.CommandTrigger).Once()
+	mockAPI.On("RegisterCommand", mock.MatchedBy(func(c *model.Command) bool { return c.Trigger == "hello" })).
+		Run(func(args mock.Arguments) { registered = args.Get(0).(*model.Command) }).
+		Return(nil).Once()
+	require.NoError(t, s.registerAppCommands(app1))
+
+	require.Equal(t, "app1", registered.DisplayName)
+	require.Equal(t, "hello commands", registered.AutoCompleteDesc)
+	ad := registered.AutocompleteData
+	require.NotNil(t, ad)
+	require.NoError(t, ad.IsValid())
+	require.Len(t, ad.SubCommands, 2)
+	message := ad.SubCommands[0]
+	require.Equal(t, "message", message.Trigger)
+	require.Equal(t, model.SYSTEM_USER_ROLE_ID, message.RoleID)
+	require.Len(t, message.Arguments, 3)
+	require.Equal(t, "", message.Arguments[0].Name)
+	require.Equal(t, model.AutocompleteArgTypeText, message.Arguments[0].Type)
+	require.Equal(t, "user", message.Arguments[1].Name)
+	require.Equal(t, "urgent", message.Arguments[2].Name)
+	require.Equal(t, model.AutocompleteArgTypeStaticList, message.Arguments[2].Type)
+	admin := ad.SubCommands[1]
+	require.Equal(t, model.SYSTEM_ADMIN_ROLE_ID, admin.RoleID)
+	require.Empty(t, admin.Arguments)
+
+	// A trigger that is already taken by another App is not registered.
+	client.bindings["app2"] = testCommandBindings("other", "hello")
+	mockAPI.On("RegisterCommand", mock.MatchedBy(func(c *model.Command) bool { return c.Trigger == "other" })).
+		Run(func(args mock.Arguments) { registered = args.Get(0).(*model.Command) }).
+		Return(nil).Once()
+	mockAPI.On("LogWarn", "app command is already registered by another app", "app_id", apps.AppID("app2"), "trigger", "hello", "registered_by", apps.AppID("app1")).Once()
+	require.NoError(t, s.registerAppCommands(app2))
+	require.Empty(t, registered.AutocompleteData.SubCommands[0].Arguments)
+	require.Empty(t, client.formCalls)
+
+	// The new commands are registered even if the old ones fail to be
+	// unregistered, which is tried again the next time.
+	client.bindings["app1"] = testCommandBindings("new")
+	mockAPI.On("UnregisterCommand", "", "hello").Return(&model.AppError{Message: "failed"}).Once()
+	mockAPI.On("LogWarn", "failed to unregister app command", "app_id", apps.AppID("app1"), "trigger", "hello", "err", mock.Anything).Once()
+	mockAPI.On("RegisterCommand", mock.MatchedBy(func(c *model.Command) bool { return c.Trigger == "new" })).Return(nil).Twice()
+	require.NoError(t, s.registerAppCommands(app1))
+	appID, ok := s.commands.appID("new")
+	require.True(t, ok)
+	require.Equal(t, apps.AppID("app1"), appID)
+	_, ok = s.commands.appID("hello")
+	require.True(t, ok)
+
+	// The commands the App no longer has are unregistered.
+	mockAPI.On("UnregisterCommand", "", "hello").Return(nil).Once()
+	require.NoError(t, s.registerAppCommands(app1))
+	_, ok = s.commands.appID("hello")
+	require.False(t, ok)
+	appID, ok = s.commands.appID("other")
+	require.True(t, ok)
+	require.Equal(t, apps.AppID("app2"), appID)
+}
+
+func TestExecuteCommand(t *testing.T) {
+	app := &apps.App{
+		Manifest:         &apps.Manifest{AppID: "app"},
+		GrantedLocations: apps.Locations{apps.LocationCommand},
+	}
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	s := newTestService(mockAPI, app)
+	client := &testBindingsClient{
+		bindings: map[apps.AppID][]*apps.Binding{
+			"app": testCommandBindings("hello"),
+		},
+		forms: map[string]*apps.Form{
+			"/message": testCommandForm,
+		},
+	}
+	s.Client = client
+	mockAPI.On("RegisterCommand", mock.Anything).Return(nil).Once()
+	require.NoError(t, s.registerAppCommands(app))
+
+	mockAPI.On("KVGet", "bindings_inv").Return(nil, nil)
+	mockAPI.On("GetUser", "user-id").Return(&model.User{Id: "user-id", Roles: model.SYSTEM_USER_ROLE_ID}, nil)
+	cc := &apps.Context{ActingUserID: "user-id"}
+
+	mockAPI.On("GetUserByUsername", "bob").Return(&model.User{Id: "bob-id"}, nil)
+	mockAPI.On("GetUserByUsername", "nobody").Return(nil, &model.AppError{Message: "not found"})
+	res, err := s.ExecuteCommand(cc, `/hello message --user @bob "hi there"`)
+	require.NoError(t, err)
+	require.Equal(t, apps.CallResponseTypeOK, res.Type)
+	require.Len(t, client.posted, 1)
+	call := client.posted[0]
+	require.Equal(t, "/message", call.URL)
+	require.Equal(t, `/hello message --user @bob "hi there"`, call.RawCommand)
+	require.Equal(t, apps.CallValues{"mode": "dm", "userID": "bob-id", "message": "hi there"}, call.Values)
+	require.Equal(t, apps.AppID("app"), call.Context.AppID)
+	require.Equal(t, "user-id", call.Context.ActingUserID)
+
+	for command, expectedError := range map[string]string{
+		"/hello":                          "expected a subcommand: message",
+		"/hello admin":                    `unknown subcommand "admin", expected one of: message`,
+		"/hello message --x y":            "unknown flag --x",
+		"/unknown message":                "/unknown is not an app command: not found",
+		"/hello message hi there":         `unexpected argument "there"`,
+		"/hello message 'hi":              "unterminated ' quote",
+		"/hello message h --user @nobody": "invalid arguments: message: must be at least 2 characters, userID: user @nobody not found",
+	} {
+		_, err = s.ExecuteCommand(cc, command)
+		require.EqualError(t, err, expectedError, command)
+		if cerr, ok := err.(*apps.CommandError); ok && len(cerr.FieldErrors) > 0 {
+			require.Equal(t, "must be at least 2 characters", cerr.FieldErrors["message"])
+		}
+	}
+	require.Len(t, client.posted, 1)
+}
+
+func TestExecuteCommandDependsOn(t *testing.T) {
+	app := &apps.App{
+		Manifest:         &apps.Manifest{AppID: "app"},
+		GrantedLocations: apps.Locations{apps.LocationCommand},
+	}
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	s := newTestService(mockAPI, app)
+	client := &testBindingsClient{
+		bindings: map[apps.AppID][]*apps.Binding{
+			"app": testCommandBindings("hello"),
+		},
+		forms: map[string]*apps.Form{
+			"/message": {
+				DependsOn: []string{"mode"},
+				Fields: []*apps.Field{
+					{Name: "mode", Type: apps.FieldTypeText, Label: "mode"},
+					{Name: "message", Type: apps.FieldTypeText, AutocompletePosition: 1},
+				},
+			},
+		},
+	}
+	s.Client = client
+	mockAPI.On("RegisterCommand", mock.Anything).Return(nil).Once()
+	require.NoError(t, s.registerAppCommands(app))
+	mockAPI.On("KVGet", "bindings_inv").Return(nil, nil)
+	mockAPI.On("GetUser", "user-id").Return(&model.User{Id: "user-id", Roles: model.SYSTEM_USER_ROLE_ID}, nil)
+	cc := &apps.Context{ActingUserID: "user-id"}
+
+	// The binding's own mode does not change the form.
+	client.formCalls = nil
+	_, err := s.ExecuteCommand(cc, "/hello message hi")
+	require.NoError(t, err)
+	require.Len(t, client.formCalls, 1)
+
+	// A different mode fetches the form again, for the new values.
+	client.formCalls = nil
+	_, err = s.ExecuteCommand(cc, "/hello message --mode channel hi")
+	require.NoError(t, err)
+	require.Len(t, client.formCalls, 2)
+	require.Equal(t, apps.CallValues{"mode": "channel", "message": "hi"}, client.formCalls[1].Values)
+	require.Equal(t, apps.CallValues{"mode": "channel", "message": "hi"}, client.posted[1].Values)
+}
+
+func TestExecuteCommandEmbeddedDependsOn(t *testing.T) {
+	app := &apps.App{
+		Manifest:         &apps.Manifest{AppID: "app"},
+		GrantedLocations: apps.Locations{apps.LocationCommand},
+	}
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	s := newTestService(mockAPI, app)
+	bindings := testCommandBindings("hello")
+	bindings[0].Bindings[0].Bindings[0].Form = &apps.Form{
+		DependsOn: []string{"mode"},
+		Fields: []*apps.Field{
+			{Name: "mode", Type: apps.FieldTypeText, Label: "mode"},
+			{Name: "message", Type: apps.FieldTypeText, IsRequired: true, AutocompletePosition: 1},
+		},
+	}
+	client := &testBindingsClient{
+		bindings: map[apps.AppID][]*apps.Binding{
+			"app": bindings,
+		},
+	}
+	s.Client = client
+	mockAPI.On("RegisterCommand", mock.Anything).Return(nil).Once()
+	require.NoError(t, s.registerAppCommands(app))
+	mockAPI.On("KVGet", "bindings_inv").Return(nil, nil)
+	mockAPI.On("GetUser", "user-id").Return(&model.User{Id: "user-id", Roles: model.SYSTEM_USER_ROLE_ID}, nil)
+	cc := &apps.Context{ActingUserID: "user-id"}
+
+	// The embedded form is not refreshed for the changed mode, the values
+	// are validated against it and submitted.
+	res, err := s.ExecuteCommand(cc, "/hello message --mode channel hi")
+	require.NoError(t, err)
+	require.Equal(t, apps.CallResponseTypeOK, res.Type)
+	require.Empty(t, client.formCalls)
+	require.Len(t, client.posted, 1)
+	require.Equal(t, apps.CallValues{"mode": "channel", "message": "hi"}, client.posted[0].Values)
+
+	_, err = s.ExecuteCommand(cc, "/hello message --mode channel")
+	require.EqualError(t, err, "invalid arguments: message: is required")
+	require.Len(t, client.posted, 1)
+}
+
+func TestCommandResolverChannels(t *testing.T) {
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	r := &commandResolver{
+		mm:           pluginapi.NewClient(mockAPI),
+		teamID:       "team-id",
+		actingUserID: "user-id",
+	}
+
+	mockAPI.On("GetChannelByName", "team-id", "town-square", false).Return(&model.Channel{Id: "town-square-id"}, nil)
+	mockAPI.On("GetChannelMember", "town-square-id", "user-id").Return(&model.ChannelMember{ChannelId: "town-square-id", UserId: "user-id"}, nil)
+	id, err := r.ResolveChannel("town-square")
+	require.NoError(t, err)
+	require.Equal(t, "town-square-id", id)
+
+	// The channels the acting user is not a member of are not found, by name
+	// or by ID.
+	mockAPI.On("GetChannelByName", "team-id", "secret", false).Return(&model.Channel{Id: "secret-id"}, nil)
+	mockAPI.On("GetChannelMember", "secret-id", "user-id").Return(nil, &model.AppError{Message: "not found"})
+	_, err = r.ResolveChannel("secret")
+	require.Error(t, err)
+	_, err = r.ResolveChannelID("secret-id")
+	require.Error(t, err)
+}
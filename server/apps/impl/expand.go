@@ -2,10 +2,16 @@ package impl
 
 import (
 	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+	"github.com/mattermost/mattermost-plugin-apps/server/utils"
 	"github.com/mattermost/mattermost-server/v5/model"
 	"github.com/pkg/errors"
 )
 
+const (
+	defaultMaxExpandThreadPosts         = 20
+	defaultMaxExpandChannelHistoryPosts = 20
+)
+
 type expander struct {
 	*apps.Context
 	s *service
@@ -96,20 +102,173 @@ func (e *expander) Expand(expand *apps.Expand) (*apps.Context, error) {
 		e.User = user
 	}
 
+	// Thread and ChannelHistory are only expanded on behalf of an acting user
+	// that can read the channel.
+	if expand.Thread != "" && e.ActingUserID != "" && e.Thread == nil {
+		rootID := e.RootPostID
+		if rootID == "" {
+			rootID = e.PostID
+		}
+		if rootID != "" {
+			thread, err := e.getThread(rootID)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to expand thread %s", rootID)
+			}
+			e.Thread = thread
+		}
+	}
+
+	if expand.ChannelHistory != "" && e.ActingUserID != "" && e.ChannelID != "" && e.ChannelHistory == nil {
+		history, err := e.getChannelHistory(e.ChannelID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to expand channel history %s", e.ChannelID)
+		}
+		e.ChannelHistory = history
+	}
+
+	// The acting user's access token is the OAuth2 token the user connected
+	// to the App with. It is only passed on to Apps that are allowed to act as
+	// the user.
+	actingUserAccessToken := ""
+	if expand.ActingUserAccessToken != "" && e.ActingUserID != "" {
+		err := e.checkAppPermission(apps.PermissionActAsUser)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to expand acting user access token")
+		}
+		if e.ActingUserAccessToken == "" {
+			token, err := e.s.getOAuth2Token(e.AppID, e.ActingUserID)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to expand acting user access token")
+			}
+			e.ActingUserAccessToken = token
+		}
+		actingUserAccessToken = e.ActingUserAccessToken
+	}
+
+	if expand.RemoteOAuth2 != "" && e.ActingUserID != "" && e.AppID != "" && e.RemoteOAuth2 == nil {
+		app := e.App
+		if app == nil {
+			var err error
+			app, err = e.s.GetApp(e.AppID)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to expand remote OAuth2 tokens for %s", e.AppID)
+			}
+		}
+		tokens, err := e.s.getRemoteOAuth2Tokens(app, e.ActingUserID)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to expand remote OAuth2 tokens")
+		}
+		e.RemoteOAuth2 = tokens
+	}
+
 	clone.ExpandedContext = apps.ExpandedContext{
-		ActingUser: e.stripUser(e.ActingUser, expand.ActingUser),
-		App:        e.stripApp(expand.App),
-		Channel:    e.stripChannel(expand.Channel),
-		Config:     e.stripConfig(expand.Config),
-		Post:       e.stripPost(e.Post, expand.Post),
-		RootPost:   e.stripPost(e.RootPost, expand.RootPost),
-		Team:       e.stripTeam(expand.Team),
-		User:       e.stripUser(e.User, expand.User),
+		ActingUser:            e.stripUser(e.ActingUser, expand.ActingUser),
+		ActingUserAccessToken: actingUserAccessToken,
+		App:                   e.stripApp(expand.App),
+		Channel:               e.stripChannel(expand.Channel),
+		ChannelHistory:        e.stripPosts(e.ChannelHistory, expand.ChannelHistory),
+		Config:                e.stripConfig(expand.Config),
+		Post:                  e.stripPost(e.Post, expand.Post),
+		RemoteOAuth2:          e.stripRemoteOAuth2(expand.RemoteOAuth2),
+		RootPost:              e.stripPost(e.RootPost, expand.RootPost),
+		Team:                  e.stripTeam(expand.Team),
+		Thread:                e.stripPosts(e.Thread, expand.Thread),
+		User:                  e.stripUser(e.User, expand.User),
 		// TODO Mentioned
 	}
 	return &clone, nil
 }
 
+func (e *expander) checkAppPermission(permission apps.PermissionType) error {
+	if e.AppID == "" {
+		return errors.New("no app to check permissions for")
+	}
+	app := e.App
+	if app == nil {
+		var err error
+		app, err = e.s.GetApp(e.AppID)
+		if err != nil {
+			return err
+		}
+	}
+	if !app.GrantedPermissions.Contains(permission) {
+		return errors.Errorf("app %s is not granted permission %s", e.AppID, permission)
+	}
+	return nil
+}
+
+func (e *expander) getThread(rootID string) ([]*model.Post, error) {
+	list, err := e.s.Mattermost.Post.GetPostThread(rootID)
+	if err != nil {
+		return nil, err
+	}
+	root := list.Posts[rootID]
+	if root == nil {
+		return nil, utils.ErrNotFound
+	}
+	err = e.checkCanReadChannel(root.ChannelId)
+	if err != nil {
+		return nil, err
+	}
+
+	limit, _ := e.s.expandPostLimits()
+	return mostRecentPosts(list, limit), nil
+}
+
+func (e *expander) getChannelHistory(channelID string) ([]*model.Post, error) {
+	err := e.checkCanReadChannel(channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, limit := e.s.expandPostLimits()
+	list, err := e.s.Mattermost.Post.GetPostsForChannel(channelID, 0, limit)
+	if err != nil {
+		return nil, err
+	}
+	return mostRecentPosts(list, limit), nil
+}
+
+func (e *expander) checkCanReadChannel(channelID string) error {
+	if !e.s.Mattermost.User.HasPermissionToChannel(e.ActingUserID, channelID, model.PERMISSION_READ_CHANNEL) {
+		return errors.Errorf("user %s may not read channel %s", e.ActingUserID, channelID)
+	}
+	return nil
+}
+
+// mostRecentPosts returns up to limit most recent posts from the list, in
+// chronological order.
+func mostRecentPosts(list *model.PostList, limit int) []*model.Post {
+	list.SortByCreateAt()
+	posts := list.ToSlice()
+	if len(posts) > limit {
+		posts = posts[:limit]
+	}
+
+	out := make([]*model.Post, 0, len(posts))
+	for i := len(posts) - 1; i >= 0; i-- {
+		out = append(out, posts[i])
+	}
+	return out
+}
+
+// expandPostLimits returns the maximum number of posts to include in the
+// Thread and ChannelHistory expansions.
+func (s *service) expandPostLimits() (thread, channelHistory int) {
+	thread, channelHistory = defaultMaxExpandThreadPosts, defaultMaxExpandChannelHistoryPosts
+	conf := s.Configurator.GetConfig()
+	if conf.StoredConfig == nil {
+		return thread, channelHistory
+	}
+	if conf.MaxExpandThreadPosts > 0 {
+		thread = conf.MaxExpandThreadPosts
+	}
+	if conf.MaxExpandChannelHistoryPosts > 0 {
+		channelHistory = conf.MaxExpandChannelHistoryPosts
+	}
+	return thread, channelHistory
+}
+
 func (e *expander) stripUser(user *model.User, level apps.ExpandLevel) *model.User {
 	if user == nil || level == apps.ExpandAll {
 		return user
@@ -175,15 +334,32 @@ func (e *expander) stripPost(post *model.Post, level apps.ExpandLevel) *model.Po
 		return nil
 	}
 	return &model.Post{
-		Id:        e.Post.Id,
-		Type:      e.Post.Type,
-		UserId:    e.Post.UserId,
-		ChannelId: e.Post.ChannelId,
-		RootId:    e.Post.RootId,
-		Message:   e.Post.Message,
+		Id:        post.Id,
+		Type:      post.Type,
+		UserId:    post.UserId,
+		ChannelId: post.ChannelId,
+		RootId:    post.RootId,
+		Message:   post.Message,
 	}
 }
 
+func (e *expander) stripPosts(posts []*model.Post, level apps.ExpandLevel) []*model.Post {
+	if posts == nil {
+		return nil
+	}
+	switch level {
+	case apps.ExpandAll, apps.ExpandSummary:
+	default:
+		return nil
+	}
+
+	out := make([]*model.Post, 0, len(posts))
+	for _, post := range posts {
+		out = append(out, e.stripPost(post, level))
+	}
+	return out
+}
+
 func (e *expander) stripApp(level apps.ExpandLevel) *apps.App {
 	if e.App == nil {
 		return nil
@@ -201,6 +377,14 @@ func (e *expander) stripApp(level apps.ExpandLevel) *apps.App {
 	return nil
 }
 
+func (e *expander) stripRemoteOAuth2(level apps.ExpandLevel) map[string]*apps.RemoteOAuth2Token {
+	switch level {
+	case apps.ExpandAll, apps.ExpandSummary:
+		return e.RemoteOAuth2
+	}
+	return nil
+}
+
 func (e *expander) stripConfig(level apps.ExpandLevel) *apps.MattermostConfig {
 	if e.Config == nil {
 		return nil
@@ -0,0 +1,790 @@
+package impl
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	pluginapi "github.com/mattermost/mattermost-plugin-api"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+	"github.com/mattermost/mattermost-plugin-apps/server/apps/store"
+	"github.com/mattermost/mattermost-plugin-apps/server/configurator"
+)
+
+func newTestService(mockAPI *plugintest.API, installed ...*apps.App) *service {
+	stored := &configurator.StoredConfig{
+		Apps:          map[string]interface{}{},
+		EncryptionKey: "encryption-key",
+	}
+	for _, app := range installed {
+		stored.Apps[string(app.Manifest.AppID)] = app.ConfigMap()
+	}
+	conf := configurator.NewTestConfigurator(&configurator.Config{
+		StoredConfig: stored,
+		BuildConfig:  &configurator.BuildConfig{},
+	})
+
+	mm := pluginapi.NewClient(mockAPI)
+	s := &service{
+		Service: apps.Service{
+			Configurator: conf,
+			Mattermost:   mm,
+		},
+		Store:          newTestFormsStore(store.NewService(mm, conf)),
+		bindingsErrors: &sync.Map{},
+		bindingsCache:  newBindingsCache(),
+		bindingsInfo:   &sync.Map{},
+		commands:       newCommandRegistry(),
+		lookups:        newLookupCache(),
+	}
+	s.API = s
+	return s
+}
+
+func TestExpand(t *testing.T) {
+	app := &apps.App{
+		Manifest: &apps.Manifest{
+			AppID:       "app-id",
+			DisplayName: "App",
+			RootURL:     "https://app.example.com",
+		},
+		Secret:             "app-secret",
+		OAuth2ClientID:     "oauth2-client-id",
+		OAuth2ClientSecret: "oauth2-client-secret",
+		BotUserID:          "bot-user-id",
+		BotUsername:        "bot_username",
+		BotAccessToken:     "bot-access-token",
+	}
+	strippedApp := *app
+	strippedApp.Secret = ""
+	strippedApp.OAuth2ClientSecret = ""
+	strippedApp.BotAccessToken = ""
+
+	actingUser := &model.User{
+		Id:        "acting-user-id",
+		Username:  "acting_user",
+		Email:     "acting@example.com",
+		FirstName: "Acting",
+		LastName:  "User",
+		Roles:     "system_user",
+		Password:  "password-hash",
+		Position:  "acting position",
+	}
+	actingUserSummary := &model.User{
+		Id:        "acting-user-id",
+		Username:  "acting_user",
+		Email:     "acting@example.com",
+		FirstName: "Acting",
+		LastName:  "User",
+		Roles:     "system_user",
+	}
+
+	user := &model.User{
+		Id:       "user-id",
+		Username: "user",
+		Email:    "user@example.com",
+		Nickname: "nick",
+		Locale:   "en",
+		Password: "password-hash",
+		Position: "user position",
+	}
+	userSummary := &model.User{
+		Id:       "user-id",
+		Username: "user",
+		Email:    "user@example.com",
+		Nickname: "nick",
+		Locale:   "en",
+	}
+
+	channel := &model.Channel{
+		Id:          "channel-id",
+		TeamId:      "team-id",
+		Type:        model.CHANNEL_OPEN,
+		DisplayName: "Channel",
+		Name:        "channel",
+		Header:      "channel header",
+		Purpose:     "channel purpose",
+	}
+	channelSummary := &model.Channel{
+		Id:          "channel-id",
+		TeamId:      "team-id",
+		Type:        model.CHANNEL_OPEN,
+		DisplayName: "Channel",
+		Name:        "channel",
+	}
+
+	team := &model.Team{
+		Id:              "team-id",
+		DisplayName:     "Team",
+		Name:            "team",
+		Description:     "team description",
+		Email:           "team@example.com",
+		Type:            model.TEAM_OPEN,
+		AllowedDomains:  "example.com",
+		AllowOpenInvite: true,
+	}
+	teamSummary := &model.Team{
+		Id:          "team-id",
+		DisplayName: "Team",
+		Name:        "team",
+		Description: "team description",
+		Email:       "team@example.com",
+		Type:        model.TEAM_OPEN,
+	}
+
+	post := &model.Post{
+		Id:        "post-id",
+		ChannelId: "channel-id",
+		UserId:    "user-id",
+		RootId:    "root-post-id",
+		Message:   "post message",
+		Hashtags:  "#post",
+		CreateAt:  2,
+		EditAt:    10,
+	}
+	postSummary := &model.Post{
+		Id:        "post-id",
+		ChannelId: "channel-id",
+		UserId:    "user-id",
+		RootId:    "root-post-id",
+		Message:   "post message",
+	}
+
+	rootPost := &model.Post{
+		Id:        "root-post-id",
+		ChannelId: "channel-id",
+		UserId:    "acting-user-id",
+		Message:   "root post message",
+		Hashtags:  "#root",
+		CreateAt:  1,
+		EditAt:    20,
+	}
+	rootPostSummary := &model.Post{
+		Id:        "root-post-id",
+		ChannelId: "channel-id",
+		UserId:    "acting-user-id",
+		Message:   "root post message",
+	}
+
+	thread := &model.PostList{
+		Order: []string{"post-id", "root-post-id"},
+		Posts: map[string]*model.Post{
+			"post-id":      post,
+			"root-post-id": rootPost,
+		},
+	}
+	history := &model.PostList{
+		Order: []string{"post-id", "root-post-id"},
+		Posts: map[string]*model.Post{
+			"post-id":      post,
+			"root-post-id": rootPost,
+		},
+	}
+
+	fullContext := func() *apps.Context {
+		return &apps.Context{
+			AppID:        "app-id",
+			ActingUserID: "acting-user-id",
+			UserID:       "user-id",
+			TeamID:       "team-id",
+			ChannelID:    "channel-id",
+			PostID:       "post-id",
+			RootPostID:   "root-post-id",
+		}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		cc       *apps.Context
+		expand   *apps.Expand
+		expected apps.ExpandedContext
+	}{
+		{
+			name:     "nil expand",
+			cc:       fullContext(),
+			expand:   nil,
+			expected: apps.ExpandedContext{},
+		},
+		{
+			name:     "empty expand",
+			cc:       fullContext(),
+			expand:   &apps.Expand{},
+			expected: apps.ExpandedContext{},
+		},
+		{
+			name:     "acting user all",
+			cc:       fullContext(),
+			expand:   &apps.Expand{ActingUser: apps.ExpandAll},
+			expected: apps.ExpandedContext{ActingUser: actingUser},
+		},
+		{
+			name:     "acting user summary",
+			cc:       fullContext(),
+			expand:   &apps.Expand{ActingUser: apps.ExpandSummary},
+			expected: apps.ExpandedContext{ActingUser: actingUserSummary},
+		},
+		{
+			name:     "app all",
+			cc:       fullContext(),
+			expand:   &apps.Expand{App: apps.ExpandAll},
+			expected: apps.ExpandedContext{App: &strippedApp},
+		},
+		{
+			name:     "app summary",
+			cc:       fullContext(),
+			expand:   &apps.Expand{App: apps.ExpandSummary},
+			expected: apps.ExpandedContext{App: &strippedApp},
+		},
+		{
+			name:     "channel all",
+			cc:       fullContext(),
+			expand:   &apps.Expand{Channel: apps.ExpandAll},
+			expected: apps.ExpandedContext{Channel: channel},
+		},
+		{
+			name:     "channel summary",
+			cc:       fullContext(),
+			expand:   &apps.Expand{Channel: apps.ExpandSummary},
+			expected: apps.ExpandedContext{Channel: channelSummary},
+		},
+		{
+			name:     "config all",
+			cc:       fullContext(),
+			expand:   &apps.Expand{Config: apps.ExpandAll},
+			expected: apps.ExpandedContext{Config: &apps.MattermostConfig{}},
+		},
+		{
+			name:     "config summary",
+			cc:       fullContext(),
+			expand:   &apps.Expand{Config: apps.ExpandSummary},
+			expected: apps.ExpandedContext{Config: &apps.MattermostConfig{}},
+		},
+		{
+			name:     "post all",
+			cc:       fullContext(),
+			expand:   &apps.Expand{Post: apps.ExpandAll},
+			expected: apps.ExpandedContext{Post: post},
+		},
+		{
+			name:     "post summary",
+			cc:       fullContext(),
+			expand:   &apps.Expand{Post: apps.ExpandSummary},
+			expected: apps.ExpandedContext{Post: postSummary},
+		},
+		{
+			name:     "root post all",
+			cc:       fullContext(),
+			expand:   &apps.Expand{RootPost: apps.ExpandAll},
+			expected: apps.ExpandedContext{RootPost: rootPost},
+		},
+		{
+			name:     "root post summary",
+			cc:       fullContext(),
+			expand:   &apps.Expand{RootPost: apps.ExpandSummary},
+			expected: apps.ExpandedContext{RootPost: rootPostSummary},
+		},
+		{
+			name:     "root post summary with post summary",
+			cc:       fullContext(),
+			expand:   &apps.Expand{Post: apps.ExpandSummary, RootPost: apps.ExpandSummary},
+			expected: apps.ExpandedContext{Post: postSummary, RootPost: rootPostSummary},
+		},
+		{
+			name:     "team all",
+			cc:       fullContext(),
+			expand:   &apps.Expand{Team: apps.ExpandAll},
+			expected: apps.ExpandedContext{Team: team},
+		},
+		{
+			name:     "team summary",
+			cc:       fullContext(),
+			expand:   &apps.Expand{Team: apps.ExpandSummary},
+			expected: apps.ExpandedContext{Team: teamSummary},
+		},
+		{
+			name:     "user all",
+			cc:       fullContext(),
+			expand:   &apps.Expand{User: apps.ExpandAll},
+			expected: apps.ExpandedContext{User: user},
+		},
+		{
+			name:     "user summary",
+			cc:       fullContext(),
+			expand:   &apps.Expand{User: apps.ExpandSummary},
+			expected: apps.ExpandedContext{User: userSummary},
+		},
+		{
+			name: "everything all",
+			cc:   fullContext(),
+			expand: &apps.Expand{
+				ActingUser: apps.ExpandAll,
+				App:        apps.ExpandAll,
+				Channel:    apps.ExpandAll,
+				Config:     apps.ExpandAll,
+				Post:       apps.ExpandAll,
+				RootPost:   apps.ExpandAll,
+				Team:       apps.ExpandAll,
+				User:       apps.ExpandAll,
+			},
+			expected: apps.ExpandedContext{
+				ActingUser: actingUser,
+				App:        &strippedApp,
+				Channel:    channel,
+				Config:     &apps.MattermostConfig{},
+				Post:       post,
+				RootPost:   rootPost,
+				Team:       team,
+				User:       user,
+			},
+		},
+		{
+			name: "everything summary",
+			cc:   fullContext(),
+			expand: &apps.Expand{
+				ActingUser: apps.ExpandSummary,
+				App:        apps.ExpandSummary,
+				Channel:    apps.ExpandSummary,
+				Config:     apps.ExpandSummary,
+				Post:       apps.ExpandSummary,
+				RootPost:   apps.ExpandSummary,
+				Team:       apps.ExpandSummary,
+				User:       apps.ExpandSummary,
+			},
+			expected: apps.ExpandedContext{
+				ActingUser: actingUserSummary,
+				App:        &strippedApp,
+				Channel:    channelSummary,
+				Config:     &apps.MattermostConfig{},
+				Post:       postSummary,
+				RootPost:   rootPostSummary,
+				Team:       teamSummary,
+				User:       userSummary,
+			},
+		},
+		{
+			name:     "thread all",
+			cc:       fullContext(),
+			expand:   &apps.Expand{Thread: apps.ExpandAll},
+			expected: apps.ExpandedContext{Thread: []*model.Post{rootPost, post}},
+		},
+		{
+			name:     "thread summary",
+			cc:       fullContext(),
+			expand:   &apps.Expand{Thread: apps.ExpandSummary},
+			expected: apps.ExpandedContext{Thread: []*model.Post{rootPostSummary, postSummary}},
+		},
+		{
+			name:     "channel history all",
+			cc:       fullContext(),
+			expand:   &apps.Expand{ChannelHistory: apps.ExpandAll},
+			expected: apps.ExpandedContext{ChannelHistory: []*model.Post{rootPost, post}},
+		},
+		{
+			name:     "channel history summary",
+			cc:       fullContext(),
+			expand:   &apps.Expand{ChannelHistory: apps.ExpandSummary},
+			expected: apps.ExpandedContext{ChannelHistory: []*model.Post{rootPostSummary, postSummary}},
+		},
+		{
+			name: "thread and channel history without acting user",
+			cc: &apps.Context{
+				ChannelID:  "channel-id",
+				PostID:     "post-id",
+				RootPostID: "root-post-id",
+			},
+			expand:   &apps.Expand{Thread: apps.ExpandAll, ChannelHistory: apps.ExpandAll},
+			expected: apps.ExpandedContext{},
+		},
+		{
+			name:     "unknown level",
+			cc:       fullContext(),
+			expand:   &apps.Expand{ActingUser: "unknown", Channel: "unknown", Post: "unknown"},
+			expected: apps.ExpandedContext{},
+		},
+		{
+			name: "root post only",
+			cc: &apps.Context{
+				AppID:      "app-id",
+				ChannelID:  "channel-id",
+				RootPostID: "root-post-id",
+			},
+			expand:   &apps.Expand{Post: apps.ExpandSummary, RootPost: apps.ExpandSummary},
+			expected: apps.ExpandedContext{RootPost: rootPostSummary},
+		},
+		{
+			name:     "missing IDs",
+			cc:       &apps.Context{},
+			expand:   &apps.Expand{ActingUser: apps.ExpandAll, Channel: apps.ExpandAll, Post: apps.ExpandAll, Team: apps.ExpandAll, User: apps.ExpandAll},
+			expected: apps.ExpandedContext{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mockAPI := &plugintest.API{}
+			mockAPI.On("GetUser", "acting-user-id").Return(actingUser, nil).Maybe()
+			mockAPI.On("GetUser", "user-id").Return(user, nil).Maybe()
+			mockAPI.On("GetChannel", "channel-id").Return(channel, nil).Maybe()
+			mockAPI.On("GetTeam", "team-id").Return(team, nil).Maybe()
+			mockAPI.On("GetPost", "post-id").Return(post, nil).Maybe()
+			mockAPI.On("GetPost", "root-post-id").Return(rootPost, nil).Maybe()
+			mockAPI.On("GetPostThread", "root-post-id").Return(thread, nil).Maybe()
+			mockAPI.On("GetPostsForChannel", "channel-id", 0, defaultMaxExpandChannelHistoryPosts).Return(history, nil).Maybe()
+			mockAPI.On("HasPermissionToChannel", "acting-user-id", "channel-id", model.PERMISSION_READ_CHANNEL).Return(true).Maybe()
+			defer mockAPI.AssertExpectations(t)
+
+			s := newTestService(mockAPI, app)
+			out, err := s.newExpander(tc.cc).Expand(tc.expand)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, out.ExpandedContext)
+
+			// The unexpanded part of the context is preserved
+			require.Equal(t, tc.cc.AppID, out.AppID)
+			require.Equal(t, tc.cc.ActingUserID, out.ActingUserID)
+			require.Equal(t, tc.cc.ChannelID, out.ChannelID)
+			require.Equal(t, tc.cc.PostID, out.PostID)
+			require.Equal(t, tc.cc.RootPostID, out.RootPostID)
+		})
+	}
+}
+
+func TestExpandErrors(t *testing.T) {
+	appErr := model.NewAppError("test", "test", nil, "test error", 0)
+
+	for _, tc := range []struct {
+		name        string
+		setup       func(*plugintest.API)
+		expand      *apps.Expand
+		expectedErr string
+	}{
+		{
+			name: "acting user",
+			setup: func(mockAPI *plugintest.API) {
+				mockAPI.On("GetUser", "acting-user-id").Return(nil, appErr)
+			},
+			expand:      &apps.Expand{ActingUser: apps.ExpandAll},
+			expectedErr: "failed to expand acting user acting-user-id: test: test, test error",
+		},
+		{
+			name:        "app",
+			setup:       func(mockAPI *plugintest.API) {},
+			expand:      &apps.Expand{App: apps.ExpandAll},
+			expectedErr: "failed to expand app app-id: not found",
+		},
+		{
+			name: "channel",
+			setup: func(mockAPI *plugintest.API) {
+				mockAPI.On("GetChannel", "channel-id").Return(nil, appErr)
+			},
+			expand:      &apps.Expand{Channel: apps.ExpandAll},
+			expectedErr: "failed to expand channel channel-id: test: test, test error",
+		},
+		{
+			name: "post",
+			setup: func(mockAPI *plugintest.API) {
+				mockAPI.On("GetPost", "post-id").Return(nil, appErr)
+			},
+			expand:      &apps.Expand{Post: apps.ExpandSummary},
+			expectedErr: "failed to expand post post-id: test: test, test error",
+		},
+		{
+			name: "root post",
+			setup: func(mockAPI *plugintest.API) {
+				mockAPI.On("GetPost", "root-post-id").Return(nil, appErr)
+			},
+			expand:      &apps.Expand{RootPost: apps.ExpandSummary},
+			expectedErr: "failed to expand root post root-post-id: test: test, test error",
+		},
+		{
+			name: "team",
+			setup: func(mockAPI *plugintest.API) {
+				mockAPI.On("GetTeam", "team-id").Return(nil, appErr)
+			},
+			expand:      &apps.Expand{Team: apps.ExpandAll},
+			expectedErr: "failed to expand team team-id: test: test, test error",
+		},
+		{
+			name: "user",
+			setup: func(mockAPI *plugintest.API) {
+				mockAPI.On("GetUser", "user-id").Return(nil, appErr)
+			},
+			expand:      &apps.Expand{User: apps.ExpandAll},
+			expectedErr: "failed to expand user user-id: test: test, test error",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mockAPI := &plugintest.API{}
+			tc.setup(mockAPI)
+			defer mockAPI.AssertExpectations(t)
+
+			s := newTestService(mockAPI)
+			_, err := s.newExpander(&apps.Context{
+				AppID:        "app-id",
+				ActingUserID: "acting-user-id",
+				UserID:       "user-id",
+				TeamID:       "team-id",
+				ChannelID:    "channel-id",
+				PostID:       "post-id",
+				RootPostID:   "root-post-id",
+			}).Expand(tc.expand)
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestExpandHistory(t *testing.T) {
+	newPost := func(id string, createAt int64) *model.Post {
+		return &model.Post{
+			Id:        id,
+			ChannelId: "channel-id",
+			RootId:    "root-post-id",
+			Message:   "message " + id,
+			CreateAt:  createAt,
+		}
+	}
+	root := newPost("root-post-id", 1)
+	root.RootId = ""
+	p2, p3, p4 := newPost("p2", 2), newPost("p3", 3), newPost("p4", 4)
+	newList := func() *model.PostList {
+		return &model.PostList{
+			Order: []string{"p2", "root-post-id", "p4", "p3"},
+			Posts: map[string]*model.Post{
+				"root-post-id": root,
+				"p2":           p2,
+				"p3":           p3,
+				"p4":           p4,
+			},
+		}
+	}
+	cc := func() *apps.Context {
+		return &apps.Context{
+			ActingUserID: "acting-user-id",
+			ChannelID:    "channel-id",
+			PostID:       "p3",
+			RootPostID:   "root-post-id",
+		}
+	}
+
+	t.Run("thread limited to most recent", func(t *testing.T) {
+		mockAPI := &plugintest.API{}
+		defer mockAPI.AssertExpectations(t)
+		mockAPI.On("GetPostThread", "root-post-id").Return(newList(), nil)
+		mockAPI.On("HasPermissionToChannel", "acting-user-id", "channel-id", model.PERMISSION_READ_CHANNEL).Return(true)
+
+		s := newTestService(mockAPI)
+		s.Configurator.GetConfig().MaxExpandThreadPosts = 2
+		out, err := s.newExpander(cc()).Expand(&apps.Expand{Thread: apps.ExpandAll})
+		require.NoError(t, err)
+		require.Equal(t, []*model.Post{p3, p4}, out.Thread)
+	})
+
+	t.Run("thread of the post when no root post", func(t *testing.T) {
+		mockAPI := &plugintest.API{}
+		defer mockAPI.AssertExpectations(t)
+		mockAPI.On("GetPostThread", "root-post-id").Return(newList(), nil)
+		mockAPI.On("HasPermissionToChannel", "acting-user-id", "channel-id", model.PERMISSION_READ_CHANNEL).Return(true)
+
+		s := newTestService(mockAPI)
+		out, err := s.newExpander(&apps.Context{
+			ActingUserID: "acting-user-id",
+			PostID:       "root-post-id",
+		}).Expand(&apps.Expand{Thread: apps.ExpandAll})
+		require.NoError(t, err)
+		require.Equal(t, []*model.Post{root, p2, p3, p4}, out.Thread)
+	})
+
+	t.Run("thread not readable", func(t *testing.T) {
+		mockAPI := &plugintest.API{}
+		defer mockAPI.AssertExpectations(t)
+		mockAPI.On("GetPostThread", "root-post-id").Return(newList(), nil)
+		mockAPI.On("HasPermissionToChannel", "acting-user-id", "channel-id", model.PERMISSION_READ_CHANNEL).Return(false)
+
+		s := newTestService(mockAPI)
+		_, err := s.newExpander(cc()).Expand(&apps.Expand{Thread: apps.ExpandAll})
+		require.EqualError(t, err, "failed to expand thread root-post-id: user acting-user-id may not read channel channel-id")
+	})
+
+	t.Run("channel history limited to most recent", func(t *testing.T) {
+		mockAPI := &plugintest.API{}
+		defer mockAPI.AssertExpectations(t)
+		mockAPI.On("HasPermissionToChannel", "acting-user-id", "channel-id", model.PERMISSION_READ_CHANNEL).Return(true)
+		mockAPI.On("GetPostsForChannel", "channel-id", 0, 3).Return(newList(), nil)
+
+		s := newTestService(mockAPI)
+		s.Configurator.GetConfig().MaxExpandChannelHistoryPosts = 3
+		out, err := s.newExpander(cc()).Expand(&apps.Expand{ChannelHistory: apps.ExpandSummary})
+		require.NoError(t, err)
+		require.Equal(t, []*model.Post{
+			{Id: "p2", ChannelId: "channel-id", RootId: "root-post-id", Message: "message p2"},
+			{Id: "p3", ChannelId: "channel-id", RootId: "root-post-id", Message: "message p3"},
+			{Id: "p4", ChannelId: "channel-id", RootId: "root-post-id", Message: "message p4"},
+		}, out.ChannelHistory)
+	})
+
+	t.Run("channel history not readable", func(t *testing.T) {
+		mockAPI := &plugintest.API{}
+		defer mockAPI.AssertExpectations(t)
+		mockAPI.On("HasPermissionToChannel", "acting-user-id", "channel-id", model.PERMISSION_READ_CHANNEL).Return(false)
+
+		s := newTestService(mockAPI)
+		_, err := s.newExpander(cc()).Expand(&apps.Expand{ChannelHistory: apps.ExpandAll})
+		require.EqualError(t, err, "failed to expand channel history channel-id: user acting-user-id may not read channel channel-id")
+	})
+}
+
+func TestOAuth2StorePrefix(t *testing.T) {
+	prefix := oauth2StorePrefix("some-rather-long-app-id-that-would-not-fit")
+	require.Len(t, prefix, 16)
+	require.Equal(t, prefix, oauth2StorePrefix("some-rather-long-app-id-that-would-not-fit"))
+	require.NotEqual(t, prefix, oauth2StorePrefix("another-app-id"))
+	require.LessOrEqual(t, len(prefix+"payload_"+model.NewId()), 50)
+}
+
+func TestExpandActingUserAccessToken(t *testing.T) {
+	newApp := func(permissions ...apps.PermissionType) *apps.App {
+		return &apps.App{
+			Manifest: &apps.Manifest{
+				AppID: "app-id",
+			},
+			GrantedPermissions: permissions,
+			OAuth2ClientID:     "client-id",
+		}
+	}
+	storedToken, _ := json.Marshal(oauth2.Token{AccessToken: "stored-token"})
+	cc := func() *apps.Context {
+		return &apps.Context{
+			AppID:        "app-id",
+			ActingUserID: "acting-user-id",
+			ExpandedContext: apps.ExpandedContext{
+				ActingUserAccessToken: "access-token",
+			},
+		}
+	}
+
+	for _, tc := range []struct {
+		name          string
+		app           *apps.App
+		cc            *apps.Context
+		expand        *apps.Expand
+		stored        []byte
+		expected      string
+		expectedError string
+	}{
+		{
+			name:     "granted",
+			app:      newApp(apps.PermissionActAsUser),
+			cc:       cc(),
+			expand:   &apps.Expand{ActingUserAccessToken: apps.ExpandAll},
+			expected: "access-token",
+		},
+		{
+			name:     "not requested",
+			app:      newApp(apps.PermissionActAsUser),
+			cc:       cc(),
+			expand:   &apps.Expand{Config: apps.ExpandAll},
+			expected: "",
+		},
+		{
+			name:     "not available",
+			app:      newApp(apps.PermissionActAsUser),
+			cc:       &apps.Context{AppID: "app-id"},
+			expand:   &apps.Expand{ActingUserAccessToken: apps.ExpandAll},
+			expected: "",
+		},
+		{
+			name:     "from store",
+			app:      newApp(apps.PermissionActAsUser),
+			cc:       &apps.Context{AppID: "app-id", ActingUserID: "acting-user-id"},
+			expand:   &apps.Expand{ActingUserAccessToken: apps.ExpandAll},
+			stored:   storedToken,
+			expected: "stored-token",
+		},
+		{
+			name:          "not connected",
+			app:           newApp(apps.PermissionActAsUser),
+			cc:            &apps.Context{AppID: "app-id", ActingUserID: "acting-user-id"},
+			expand:        &apps.Expand{ActingUserAccessToken: apps.ExpandAll},
+			expectedError: "failed to expand acting user access token: user has not connected their account to the app",
+		},
+		{
+			name:          "not granted",
+			app:           newApp(apps.PermissionActAsBot),
+			cc:            cc(),
+			expand:        &apps.Expand{ActingUserAccessToken: apps.ExpandAll},
+			expectedError: "failed to expand acting user access token: app app-id is not granted permission act_as_user",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mockAPI := &plugintest.API{}
+			defer mockAPI.AssertExpectations(t)
+
+			if tc.cc.ActingUserID != "" && tc.cc.ActingUserAccessToken == "" {
+				mockAPI.On("KVGet", oauth2StorePrefix("app-id")+"token_acting-user-id").Return(tc.stored, nil)
+			}
+
+			s := newTestService(mockAPI, tc.app)
+			out, err := s.newExpander(tc.cc).Expand(tc.expand)
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, out.ActingUserAccessToken)
+		})
+	}
+}
+
+func TestExpandRemoteOAuth2(t *testing.T) {
+	app := &apps.App{
+		Manifest: &apps.Manifest{
+			AppID: "app-id",
+			RemoteOAuth2Providers: []*apps.RemoteOAuth2Provider{
+				{ID: "github", AuthURL: "https://github.example.com/auth", TokenURL: "https://github.example.com/token"},
+				{ID: "jira", AuthURL: "https://jira.example.com/auth", TokenURL: "https://jira.example.com/token"},
+				{ID: "unconfigured", AuthURL: "https://other.example.com/auth", TokenURL: "https://other.example.com/token"},
+			},
+		},
+	}
+
+	// KV is backed by a map, so that the values are stored encrypted.
+	kv := map[string][]byte{}
+	mockAPI := &plugintest.API{}
+	mockAPI.On("KVSetWithOptions", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			kv[args.String(0)] = args.Get(1).([]byte)
+		}).
+		Return(true, nil)
+	mockAPI.On("KVGet", mock.Anything).Return(func(key string) []byte { return kv[key] }, nil)
+
+	s := newTestService(mockAPI, app)
+	for _, providerID := range []string{"github", "jira"} {
+		err := s.ConfigureRemoteOAuth2("app-id", providerID, &apps.RemoteOAuth2Client{
+			ClientID:     "client-id",
+			ClientSecret: "client-secret",
+		})
+		require.NoError(t, err)
+	}
+	err := s.Store.StoreRemoteOAuth2Token("app-id", "github", "acting-user-id", &oauth2.Token{
+		AccessToken: "github-token",
+		Expiry:      time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	out, err := s.newExpander(&apps.Context{
+		AppID:        "app-id",
+		ActingUserID: "acting-user-id",
+	}).Expand(&apps.Expand{RemoteOAuth2: apps.ExpandAll})
+	require.NoError(t, err)
+	require.Equal(t, map[string]*apps.RemoteOAuth2Token{
+		"github": {AccessToken: "github-token"},
+		"jira":   {ConnectURL: "/remote-oauth2/app-id/jira/connect"},
+	}, out.RemoteOAuth2)
+
+	err = s.ConfigureRemoteOAuth2("app-id", "gitlab", &apps.RemoteOAuth2Client{ClientID: "id", ClientSecret: "secret"})
+	require.EqualError(t, err, "app app-id does not declare OAuth2 provider gitlab")
+}
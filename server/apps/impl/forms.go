@@ -0,0 +1,157 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package impl
+
+import (
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+	"github.com/mattermost/mattermost-plugin-apps/server/apps/store"
+)
+
+// callFormTTL is how long the forms given to the users are kept, to validate
+// the values submitted with them.
+const callFormTTL = time.Hour
+
+// getCallForm returns the form that the user was given for the Call, if any.
+// Only the forms that the user has seen are used, since the Apps may also
+// bind the same Call without a form, e.g. in the post menu. Without a stored
+// form, the form embedded in the bindings that call the URL is used. The
+// bindings are the ones for the Call's context, fetched or cached on any
+// node. The lookups are not for forms.
+func (s *service) getCallForm(c *apps.Call) *store.CallForm {
+	if c.Context.ActingUserID == "" || c.Type == apps.CallTypeLookup {
+		return nil
+	}
+	form, err := s.Store.GetCallForm(c.Context.AppID, c.Context.ActingUserID, c.URL)
+	if err != nil {
+		s.Mattermost.Log.Debug("failed to get the call form", "app_id", c.Context.AppID, "url", c.URL, "err", err.Error())
+		return nil
+	}
+	if form != nil && form.Form != nil {
+		return form
+	}
+	if c.Type != apps.CallTypeSubmit && c.Type != apps.CallTypeForm {
+		return nil
+	}
+
+	bindings, err := s.fetchContextBindings(c.Context)
+	if err != nil {
+		s.Mattermost.Log.Debug("failed to get the bindings for the call form", "app_id", c.Context.AppID, "url", c.URL, "err", err.Error())
+		return nil
+	}
+	if embedded := embeddedCallForm(bindings, c.URL); embedded != nil {
+		return &store.CallForm{
+			Form:     embedded,
+			Values:   embedded.DefaultValues(),
+			Embedded: true,
+		}
+	}
+	return nil
+}
+
+// embeddedCallForm returns the form embedded in the bindings that call url.
+// There is none if the URL is also bound without a form.
+func embeddedCallForm(bindings []*apps.Binding, url string) *apps.Form {
+	var form *apps.Form
+	seen := false
+	var scan func([]*apps.Binding)
+	scan = func(bindings []*apps.Binding) {
+		for _, b := range bindings {
+			if b.Call != nil && b.Call.URL == url {
+				if !seen || b.Form == nil {
+					form = b.Form
+				}
+				seen = true
+			}
+			scan(b.Bindings)
+		}
+	}
+	scan(bindings)
+	return form
+}
+
+// checkSubmit returns the response to send back instead of submitting the
+// Call to the App, if the values are not valid for the form the user was given.
+// If a field that the form DependsOn has changed, the form is refreshed for
+// the new values first. The forms embedded in the bindings are not refreshed,
+// nor the ones of the commands, that are refreshed as the command is parsed.
+func (s *service) checkSubmit(c *apps.Call, submitted *store.CallForm) (*apps.CallResponse, error) {
+	if c.Type != apps.CallTypeSubmit || submitted == nil {
+		return nil, nil
+	}
+
+	refresh := !submitted.Embedded && c.RawCommand == ""
+	if refresh && submitted.Form.DependsOnChanged(submitted.Values, c.Values) {
+		formCall := *c
+		formCall.Type = apps.CallTypeForm
+		return s.API.Call(&formCall)
+	}
+
+	fieldErrors := submitted.Form.ValidateValues(c.Values)
+	s.checkFiles(c, submitted.Form, fieldErrors)
+	if len(fieldErrors) == 0 {
+		return nil, nil
+	}
+	return &apps.CallResponse{
+		Type:        apps.CallResponseTypeForm,
+		Form:        submitted.Form,
+		Error:       "invalid values",
+		FieldErrors: fieldErrors,
+	}, nil
+}
+
+// checkFiles checks that the values of the file fields are the files that the
+// acting user has uploaded, so that the App is not given the files of others.
+func (s *service) checkFiles(c *apps.Call, form *apps.Form, fieldErrors map[string]string) {
+	for _, f := range form.Fields {
+		if f.Type != apps.FieldTypeFile || fieldErrors[f.Name] != "" {
+			continue
+		}
+		for _, fileID := range c.Values.GetList(f.Name) {
+			info, err := s.Mattermost.File.GetInfo(fileID)
+			if err != nil || info.CreatorId != c.Context.ActingUserID || info.DeleteAt != 0 {
+				fieldErrors[f.Name] = "is not a file uploaded by the user"
+				break
+			}
+		}
+	}
+}
+
+// updateCallForm keeps the form that the App returned to the user, applies
+// the form updates to it, or drops it once it is submitted and the App has
+// accepted the values. The forms that are refreshed keep the values the user
+// has entered.
+func (s *service) updateCallForm(c *apps.Call, submitted *store.CallForm, res *apps.CallResponse) {
+	appID, userID := c.Context.AppID, c.Context.ActingUserID
+	if userID == "" {
+		return
+	}
+
+	var err error
+	switch {
+	case res.Type == apps.CallResponseTypeForm && res.Form != nil:
+		if c.Type == apps.CallTypeForm {
+			res.Form = res.Form.WithValues(c.Values)
+		}
+		// The user is given the form with the default values of its fields,
+		// and the ones they entered if it was refreshed.
+		err = s.Store.StoreCallForm(appID, userID, c.URL, &store.CallForm{
+			Form:   res.Form,
+			Values: res.Form.DefaultValues(),
+		}, callFormTTL)
+
+	case res.Type == apps.CallResponseTypeFormUpdate && res.Form != nil && submitted != nil:
+		err = s.Store.StoreCallForm(appID, userID, c.URL, &store.CallForm{
+			Form:   submitted.Form.Update(res.Form),
+			Values: c.Values,
+		}, callFormTTL)
+
+	case c.Type == apps.CallTypeSubmit && submitted != nil && !submitted.Embedded && res.Type != apps.CallResponseTypeError:
+		err = s.Store.DeleteCallForm(appID, userID, c.URL)
+	}
+	if err != nil {
+		s.Mattermost.Log.Debug("failed to update the call form", "app_id", appID, "url", c.URL, "err", err.Error())
+	}
+}
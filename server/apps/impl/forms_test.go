@@ -0,0 +1,421 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package impl
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+	"github.com/mattermost/mattermost-plugin-apps/server/apps/store"
+)
+
+// testFormsStore keeps the call forms in memory, the rest is passed to the
+// store.
+type testFormsStore struct {
+	store.Service
+
+	mu    sync.Mutex
+	forms map[string]*store.CallForm
+}
+
+func newTestFormsStore(s store.Service) *testFormsStore {
+	return &testFormsStore{
+		Service: s,
+		forms:   map[string]*store.CallForm{},
+	}
+}
+
+func (s *testFormsStore) StoreCallForm(appID apps.AppID, userID, url string, form *store.CallForm, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.forms[string(appID)+"|"+userID+"|"+url] = form
+	return nil
+}
+
+func (s *testFormsStore) GetCallForm(appID apps.AppID, userID, url string) (*store.CallForm, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.forms[string(appID)+"|"+userID+"|"+url], nil
+}
+
+func (s *testFormsStore) DeleteCallForm(appID apps.AppID, userID, url string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.forms, string(appID)+"|"+userID+"|"+url)
+	return nil
+}
+
+func TestCallValidatesForm(t *testing.T) {
+	app := &apps.App{
+		Manifest:         &apps.Manifest{AppID: "app"},
+		GrantedLocations: apps.Locations{apps.LocationChannelHeader},
+	}
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	s := newTestService(mockAPI, app)
+	form := &apps.Form{
+		Fields: []*apps.Field{
+			{
+				Name:          "message",
+				Type:          apps.FieldTypeText,
+				IsRequired:    true,
+				TextMaxLength: 5,
+			}, {
+				Name: "color",
+				Type: apps.FieldTypeStaticSelect,
+				SelectStaticOptions: []apps.SelectOption{
+					{Label: "Red", Value: "red"},
+					{Label: "Blue", Value: "blue"},
+				},
+			},
+		},
+	}
+	client := &testBindingsClient{
+		bindings: map[apps.AppID][]*apps.Binding{
+			"app": {{Location: apps.LocationChannelHeader, Call: &apps.Call{URL: "/send"}}},
+		},
+		forms: map[string]*apps.Form{
+			"/send": form,
+		},
+	}
+	s.Client = client
+	mockAPI.On("KVGet", "bindings_inv").Return(nil, nil)
+	call := func(callType apps.CallType, values apps.CallValues, userID string) *apps.CallResponse {
+		res, err := s.Call(&apps.Call{
+			URL:     "/send",
+			Type:    callType,
+			Values:  values,
+			Context: &apps.Context{AppID: "app", ActingUserID: userID},
+		})
+		require.NoError(t, err)
+		return res
+	}
+
+	// Without a form, the values are not validated.
+	res := call(apps.CallTypeSubmit, nil, "user1")
+	require.Equal(t, apps.CallResponseTypeOK, res.Type)
+	require.Len(t, client.posted, 1)
+
+	res = call(apps.CallTypeForm, nil, "user1")
+	require.Equal(t, apps.CallResponseTypeForm, res.Type)
+
+	// The invalid values are returned with the form, and not sent to the App.
+	res = call(apps.CallTypeSubmit, apps.CallValues{"message": "too long", "color": "green"}, "user1")
+	require.Equal(t, apps.CallResponseTypeForm, res.Type)
+	require.Equal(t, form, res.Form)
+	require.Equal(t, map[string]string{
+		"message": "must be at most 5 characters",
+		"color":   "is not one of the options",
+	}, res.FieldErrors)
+	res = call(apps.CallTypeSubmit, apps.CallValues{}, "user1")
+	require.Equal(t, map[string]string{"message": "is required"}, res.FieldErrors)
+	require.Len(t, client.posted, 1)
+
+	// The form is only for the user it was given to.
+	res = call(apps.CallTypeSubmit, nil, "user2")
+	require.Equal(t, apps.CallResponseTypeOK, res.Type)
+	require.Len(t, client.posted, 2)
+
+	// The form is kept if the App rejects the values.
+	client.responses = map[string]*apps.CallResponse{
+		"/send": {Type: apps.CallResponseTypeError, Error: "try again"},
+	}
+	res = call(apps.CallTypeSubmit, apps.CallValues{"message": "hi", "color": "red"}, "user1")
+	require.Equal(t, apps.CallResponseTypeError, res.Type)
+	require.Len(t, client.posted, 3)
+	res = call(apps.CallTypeSubmit, apps.CallValues{}, "user1")
+	require.Equal(t, map[string]string{"message": "is required"}, res.FieldErrors)
+	client.responses = nil
+
+	// The form is done with once valid values are submitted.
+	res = call(apps.CallTypeSubmit, apps.CallValues{"message": "hi", "color": "red"}, "user1")
+	require.Equal(t, apps.CallResponseTypeOK, res.Type)
+	require.Len(t, client.posted, 4)
+	res = call(apps.CallTypeSubmit, nil, "user1")
+	require.Equal(t, apps.CallResponseTypeOK, res.Type)
+	require.Len(t, client.posted, 5)
+}
+
+func TestCallValidatesEmbeddedForm(t *testing.T) {
+	app := &apps.App{
+		Manifest:         &apps.Manifest{AppID: "app"},
+		GrantedLocations: apps.Locations{apps.LocationChannelHeader, apps.LocationPostMenu},
+	}
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	s := newTestService(mockAPI, app)
+	form := &apps.Form{
+		Fields: []*apps.Field{
+			{Name: "message", Type: apps.FieldTypeText, IsRequired: true},
+		},
+	}
+	client := &testBindingsClient{
+		bindings: map[apps.AppID][]*apps.Binding{
+			"app": {
+				{Location: apps.LocationChannelHeader, Call: &apps.Call{URL: "/send"}, Form: form},
+				{Location: apps.LocationPostMenu, Call: &apps.Call{URL: "/post"}, Form: form},
+				{Location: apps.LocationChannelHeader, Call: &apps.Call{URL: "/post"}},
+			},
+		},
+	}
+	s.Client = client
+	mockAPI.On("KVGet", "bindings_inv").Return(nil, nil)
+	_, err := s.GetBindings(&apps.Context{ActingUserID: "user-id"})
+	require.NoError(t, err)
+	call := func(url string, values apps.CallValues) *apps.CallResponse {
+		res, err := s.Call(&apps.Call{
+			URL:     url,
+			Type:    apps.CallTypeSubmit,
+			Values:  values,
+			Context: &apps.Context{AppID: "app", ActingUserID: "user-id"},
+		})
+		require.NoError(t, err)
+		return res
+	}
+
+	// The submits of the embedded form are validated against it, and it is
+	// not dropped once submitted.
+	for i := 0; i < 2; i++ {
+		res := call("/send", apps.CallValues{})
+		require.Equal(t, map[string]string{"message": "is required"}, res.FieldErrors)
+		res = call("/send", apps.CallValues{"message": "hi"})
+		require.Equal(t, apps.CallResponseTypeOK, res.Type)
+	}
+	require.Len(t, client.posted, 2)
+
+	// A URL that is also bound without a form is not validated.
+	res := call("/post", apps.CallValues{})
+	require.Equal(t, apps.CallResponseTypeOK, res.Type)
+	require.Len(t, client.posted, 3)
+
+	// A node that has not fetched the bindings yet, e.g. after a restart,
+	// fetches them for the Call's context. The roles and the form are checked
+	// against the same, cached bindings.
+	s = newTestService(mockAPI, app)
+	s.Client = client
+	client.ttl = map[apps.AppID]time.Duration{"app": time.Minute}
+	client.takeCalls()
+	res = call("/send", apps.CallValues{})
+	require.Equal(t, map[string]string{"message": "is required"}, res.FieldErrors)
+	require.Equal(t, []string{"app:"}, client.takeCalls())
+	require.Len(t, client.posted, 3)
+}
+
+func TestCallFormDependsOn(t *testing.T) {
+	app := &apps.App{
+		Manifest:         &apps.Manifest{AppID: "app"},
+		GrantedLocations: apps.Locations{apps.LocationChannelHeader},
+	}
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	s := newTestService(mockAPI, app)
+	client := &testBindingsClient{
+		forms: map[string]*apps.Form{
+			"/send": {
+				DependsOn: []string{"type"},
+				Fields: []*apps.Field{
+					{Name: "type", Type: apps.FieldTypeStaticSelect, SelectStaticOptions: []apps.SelectOption{{Value: "dm"}, {Value: "channel"}}},
+					{Name: "user", Type: apps.FieldTypeUser, IsRequired: true},
+				},
+			},
+		},
+	}
+	s.Client = client
+	mockAPI.On("KVGet", "bindings_inv").Return(nil, nil)
+	call := func(callType apps.CallType, values apps.CallValues) *apps.CallResponse {
+		res, err := s.Call(&apps.Call{
+			URL:     "/send",
+			Type:    callType,
+			Values:  values,
+			Context: &apps.Context{AppID: "app", ActingUserID: "user1"},
+		})
+		require.NoError(t, err)
+		return res
+	}
+
+	res := call(apps.CallTypeForm, apps.CallValues{"type": "dm"})
+	require.Equal(t, apps.CallResponseTypeForm, res.Type)
+	require.Equal(t, "dm", res.Form.Fields[0].Value)
+
+	// Changing the type refreshes the form with the new values, the values of
+	// the fields that are no longer in the form are dropped.
+	client.forms["/send"] = &apps.Form{
+		DependsOn: []string{"type"},
+		Fields: []*apps.Field{
+			{Name: "type", Type: apps.FieldTypeStaticSelect, SelectStaticOptions: []apps.SelectOption{{Value: "dm"}, {Value: "channel"}}},
+			{Name: "channel", Type: apps.FieldTypeChannel, IsRequired: true},
+		},
+	}
+	res = call(apps.CallTypeSubmit, apps.CallValues{"type": "channel", "user": "user2"})
+	require.Equal(t, apps.CallResponseTypeForm, res.Type)
+	require.Len(t, res.Form.Fields, 2)
+	require.Equal(t, "channel", res.Form.Fields[0].Value)
+	require.Equal(t, "channel", res.Form.Fields[1].Name)
+	require.Empty(t, client.posted)
+	require.Len(t, client.formCalls, 2)
+	require.Equal(t, apps.CallValues{"type": "channel", "user": "user2"}, client.formCalls[1].Values)
+
+	// The refreshed form is the one the values are validated against.
+	res = call(apps.CallTypeSubmit, apps.CallValues{"type": "channel"})
+	require.Equal(t, map[string]string{"channel": "is required"}, res.FieldErrors)
+	res = call(apps.CallTypeSubmit, apps.CallValues{"type": "channel", "channel": "channel-id"})
+	require.Equal(t, apps.CallResponseTypeOK, res.Type)
+	require.Len(t, client.posted, 1)
+}
+
+func TestCallFormDependsOnDefaults(t *testing.T) {
+	app := &apps.App{
+		Manifest:         &apps.Manifest{AppID: "app"},
+		GrantedLocations: apps.Locations{apps.LocationChannelHeader},
+	}
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	s := newTestService(mockAPI, app)
+	client := &testBindingsClient{
+		forms: map[string]*apps.Form{
+			"/send": {
+				DependsOn: []string{"type"},
+				Fields: []*apps.Field{
+					{Name: "type", Type: apps.FieldTypeStaticSelect, Value: "dm", SelectStaticOptions: []apps.SelectOption{{Value: "dm"}, {Value: "channel"}}},
+					{Name: "user", Type: apps.FieldTypeUser, IsRequired: true},
+				},
+			},
+		},
+	}
+	s.Client = client
+	mockAPI.On("KVGet", "bindings_inv").Return(nil, nil)
+	call := func(callType apps.CallType, values apps.CallValues) *apps.CallResponse {
+		res, err := s.Call(&apps.Call{
+			URL:     "/send",
+			Type:    callType,
+			Values:  values,
+			Context: &apps.Context{AppID: "app", ActingUserID: "user1"},
+		})
+		require.NoError(t, err)
+		return res
+	}
+
+	// The form is made for the default values of its fields, submitting them
+	// does not refresh it.
+	res := call(apps.CallTypeForm, nil)
+	require.Equal(t, apps.CallResponseTypeForm, res.Type)
+	res = call(apps.CallTypeSubmit, apps.CallValues{"type": "dm", "user": "user2"})
+	require.Equal(t, apps.CallResponseTypeOK, res.Type)
+	require.Len(t, client.formCalls, 1)
+	require.Len(t, client.posted, 1)
+}
+
+func TestCallFormUpdate(t *testing.T) {
+	app := &apps.App{
+		Manifest:         &apps.Manifest{AppID: "app"},
+		GrantedLocations: apps.Locations{apps.LocationChannelHeader},
+	}
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	s := newTestService(mockAPI, app)
+	client := &testBindingsClient{
+		forms: map[string]*apps.Form{
+			"/send": {
+				Fields: []*apps.Field{
+					{Name: "color", Type: apps.FieldTypeStaticSelect, SelectStaticOptions: []apps.SelectOption{{Value: "red"}}},
+					{Name: "message", Type: apps.FieldTypeText},
+				},
+			},
+		},
+		responses: map[string]*apps.CallResponse{
+			"/send": {
+				Type: apps.CallResponseTypeFormUpdate,
+				Form: &apps.Form{
+					Fields: []*apps.Field{
+						{Name: "color", Type: apps.FieldTypeStaticSelect, SelectStaticOptions: []apps.SelectOption{{Value: "blue"}}},
+					},
+				},
+				FieldErrors: map[string]string{"color": "red is sold out"},
+			},
+		},
+	}
+	s.Client = client
+	mockAPI.On("KVGet", "bindings_inv").Return(nil, nil)
+	call := func(values apps.CallValues) *apps.CallResponse {
+		callType := apps.CallTypeSubmit
+		if values == nil {
+			callType = apps.CallTypeForm
+		}
+		res, err := s.Call(&apps.Call{
+			URL:     "/send",
+			Type:    callType,
+			Values:  values,
+			Context: &apps.Context{AppID: "app", ActingUserID: "user1"},
+		})
+		require.NoError(t, err)
+		return res
+	}
+
+	call(nil)
+	res := call(apps.CallValues{"color": "red"})
+	require.Equal(t, apps.CallResponseTypeFormUpdate, res.Type)
+	require.Equal(t, "red is sold out", res.FieldErrors["color"])
+
+	// The updated form is kept to validate the next submit.
+	res = call(apps.CallValues{"color": "red"})
+	require.Equal(t, map[string]string{"color": "is not one of the options"}, res.FieldErrors)
+	require.Len(t, res.Form.Fields, 2)
+	require.Len(t, client.posted, 1)
+}
+
+func TestCallFormFiles(t *testing.T) {
+	app := &apps.App{
+		Manifest:         &apps.Manifest{AppID: "app"},
+		GrantedLocations: apps.Locations{apps.LocationChannelHeader},
+	}
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	s := newTestService(mockAPI, app)
+	client := &testBindingsClient{
+		forms: map[string]*apps.Form{
+			"/upload": {
+				Fields: []*apps.Field{
+					{Name: "note", Type: apps.FieldTypeMarkdown, Description: "Upload the **report**"},
+					{Name: "report", Type: apps.FieldTypeFile, IsRequired: true},
+				},
+			},
+		},
+	}
+	s.Client = client
+	mockAPI.On("KVGet", "bindings_inv").Return(nil, nil)
+	ownFile, othersFile, deletedFile := model.NewId(), model.NewId(), model.NewId()
+	mockAPI.On("GetFileInfo", ownFile).Return(&model.FileInfo{Id: ownFile, CreatorId: "user1"}, nil)
+	mockAPI.On("GetFileInfo", othersFile).Return(&model.FileInfo{Id: othersFile, CreatorId: "user2"}, nil)
+	mockAPI.On("GetFileInfo", deletedFile).Return(&model.FileInfo{Id: deletedFile, CreatorId: "user1", DeleteAt: 1}, nil)
+	call := func(callType apps.CallType, values apps.CallValues) *apps.CallResponse {
+		res, err := s.Call(&apps.Call{
+			URL:     "/upload",
+			Type:    callType,
+			Values:  values,
+			Context: &apps.Context{AppID: "app", ActingUserID: "user1"},
+		})
+		require.NoError(t, err)
+		return res
+	}
+
+	res := call(apps.CallTypeForm, nil)
+	require.Equal(t, apps.CallResponseTypeForm, res.Type)
+	for _, fileID := range []string{othersFile, deletedFile} {
+		res = call(apps.CallTypeSubmit, apps.CallValues{"report": fileID})
+		require.Equal(t, map[string]string{"report": "is not a file uploaded by the user"}, res.FieldErrors)
+	}
+	res = call(apps.CallTypeSubmit, apps.CallValues{"report": ownFile, "note": "x"})
+	require.Equal(t, map[string]string{"note": "is read-only"}, res.FieldErrors)
+	require.Len(t, client.posted, 0)
+
+	res = call(apps.CallTypeSubmit, apps.CallValues{"report": ownFile})
+	require.Equal(t, apps.CallResponseTypeOK, res.Type)
+	require.Len(t, client.posted, 1)
+}
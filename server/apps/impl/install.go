@@ -48,7 +48,7 @@ func (s *service) InstallApp(cc *apps.Context, sessionToken apps.SessionToken, i
 	resp, err := s.API.Call(
 		&apps.Call{
 			URL: app.Manifest.RootURL + apps.AppInstallPath,
-			Values: map[string]string{
+			Values: apps.CallValues{
 				apps.PropBotAccessToken:     app.BotAccessToken,
 				apps.PropOAuth2ClientSecret: app.OAuth2ClientSecret,
 			},
@@ -62,6 +62,11 @@ func (s *service) InstallApp(cc *apps.Context, sessionToken apps.SessionToken, i
 		return nil, "", errors.Wrap(err, "Install failed")
 	}
 
+	err = s.registerAppCommands(app)
+	if err != nil {
+		s.Mattermost.Log.Warn("failed to register app commands", "app_id", app.Manifest.AppID, "err", err.Error())
+	}
+
 	return app, resp.Markdown, nil
 }
 
@@ -75,9 +80,25 @@ func (s *service) ensureOAuthApp(manifest *apps.Manifest, noUserConsent bool, ac
 	client := model.NewAPIv4Client(conf.MattermostSiteURL)
 	client.SetToken(sessionToken)
 
+	// The proxy handles the OAuth2 connect flow for the App's users, see
+	// oauth2.go. The App may still use its own callback.
+	callbackURLs := []string{s.oauth2CallbackURL(manifest.AppID)}
+	if manifest.OAuth2CallbackURL != "" {
+		callbackURLs = append(callbackURLs, manifest.OAuth2CallbackURL)
+	}
+
 	if app.OAuth2ClientID != "" {
 		oauthApp, response := client.GetOAuthApp(app.OAuth2ClientID)
 		if response.StatusCode == http.StatusOK && response.Error == nil {
+			if !containsAll(oauthApp.CallbackUrls, callbackURLs) {
+				oauthApp.CallbackUrls = callbackURLs
+				updated, response := client.UpdateOAuthApp(oauthApp)
+				if response.Error != nil {
+					return nil, errors.Wrap(response.Error, "failed to update OAuth2 App callback URLs")
+				}
+				oauthApp = updated
+			}
+
 			_ = s.Mattermost.Post.DM(app.BotUserID, actingUserID, &model.Post{
 				Message: fmt.Sprintf("Using existing OAuth2 App `%s`.", oauthApp.Id),
 			})
@@ -91,7 +112,7 @@ func (s *service) ensureOAuthApp(manifest *apps.Manifest, noUserConsent bool, ac
 		CreatorId:    actingUserID,
 		Name:         manifest.DisplayName,
 		Description:  manifest.Description,
-		CallbackUrls: []string{manifest.OAuth2CallbackURL},
+		CallbackUrls: callbackURLs,
 		Homepage:     manifest.HomepageURL,
 		IsTrusted:    noUserConsent,
 	})
@@ -108,3 +129,19 @@ func (s *service) ensureOAuthApp(manifest *apps.Manifest, noUserConsent bool, ac
 
 	return oauthApp, nil
 }
+
+func containsAll(values, required []string) bool {
+	for _, r := range required {
+		found := false
+		for _, v := range values {
+			if v == r {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package impl
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+	"github.com/mattermost/mattermost-plugin-apps/server/utils"
+)
+
+const (
+	keyLifecycleTeams         = "lc_teams"
+	prefixLifecycleChannels   = "lc_ch_"
+	lifecycleChannelsPerPage  = 200
+	lifecycleChannelsMaxPages = 50
+)
+
+// channelState is what is remembered about a channel between polls. Hash
+// covers the header and the purpose.
+type channelState struct {
+	DeleteAt int64  `json:"d,omitempty"`
+	Hash     string `json:"h,omitempty"`
+}
+
+// PollLifecycleChanges detects the team and channel changes that the server
+// has no plugin hooks for, by comparing the current state to the snapshot
+// saved on the previous poll, and notifies the subscribed Apps. The first poll
+// only saves the snapshot.
+func (s *service) PollLifecycleChanges() error {
+	teams, err := s.Mattermost.Team.List()
+	if err != nil {
+		return errors.Wrap(err, "failed to list teams")
+	}
+
+	var known map[string]int64
+	err = s.Mattermost.KV.Get(keyLifecycleTeams, &known)
+	if err != nil {
+		return err
+	}
+
+	current := map[string]int64{}
+	for _, team := range teams {
+		current[team.Id] = team.UpdateAt
+		if known != nil {
+			prev, ok := known[team.Id]
+			switch {
+			case !ok:
+				_ = s.API.Notify(apps.NewTeamContext(team), apps.SubjectTeamCreated)
+			case prev != team.UpdateAt:
+				_ = s.API.Notify(apps.NewTeamContext(team), apps.SubjectTeamUpdated)
+			}
+		}
+
+		err = s.pollChannelChanges(team.Id)
+		if err != nil {
+			s.Mattermost.Log.Warn("failed to poll channel changes", "team_id", team.Id, "err", err.Error())
+		}
+	}
+
+	_, err = s.Mattermost.KV.Set(keyLifecycleTeams, current)
+	return err
+}
+
+// pollChannelChanges detects archived, restored and updated channels in the
+// team. Only the teams with channel subscriptions are polled. The plugin API
+// can only list the public channels, so private channels are not covered.
+func (s *service) pollChannelChanges(teamID string) error {
+	key := prefixLifecycleChannels + teamID
+	if !s.hasChannelLifecycleSubs(teamID) {
+		return s.Mattermost.KV.Delete(key)
+	}
+
+	var known map[string]channelState
+	err := s.Mattermost.KV.Get(key, &known)
+	if err != nil {
+		return err
+	}
+
+	current := map[string]channelState{}
+	for page := 0; page < lifecycleChannelsMaxPages; page++ {
+		channels, err := s.Mattermost.Channel.ListPublicChannelsForTeam(teamID, page, lifecycleChannelsPerPage)
+		if err != nil {
+			return err
+		}
+		for _, ch := range channels {
+			state := channelState{Hash: channelHash(ch)}
+			current[ch.Id] = state
+			prev, ok := known[ch.Id]
+			switch {
+			case !ok:
+				// New channels are notified by the ChannelHasBeenCreated hook.
+			case prev.DeleteAt != 0:
+				_ = s.API.Notify(apps.NewChannelContext(ch), apps.SubjectChannelRestored)
+			case prev.Hash != state.Hash:
+				_ = s.API.Notify(apps.NewChannelContext(ch), apps.SubjectChannelUpdated)
+			}
+		}
+		if len(channels) < lifecycleChannelsPerPage {
+			break
+		}
+	}
+
+	// The channels that are no longer listed were archived, or made private.
+	// Archived channels are kept in the snapshot to detect restoring them.
+	for id, prev := range known {
+		if _, ok := current[id]; ok {
+			continue
+		}
+		ch, err := s.Mattermost.Channel.Get(id)
+		if err != nil || ch.DeleteAt == 0 {
+			continue
+		}
+		current[id] = channelState{DeleteAt: ch.DeleteAt, Hash: channelHash(ch)}
+		if prev.DeleteAt == 0 {
+			_ = s.API.Notify(apps.NewChannelContext(ch), apps.SubjectChannelArchived)
+		}
+	}
+
+	_, err = s.Mattermost.KV.Set(key, current)
+	return err
+}
+
+func (s *service) hasChannelLifecycleSubs(teamID string) bool {
+	for _, subject := range []apps.Subject{
+		apps.SubjectChannelArchived,
+		apps.SubjectChannelRestored,
+		apps.SubjectChannelUpdated,
+	} {
+		subs, err := s.Store.GetSubs(subject, teamID, "")
+		if err != nil && err != utils.ErrNotFound {
+			s.Mattermost.Log.Debug("failed to get subscriptions", "subject", subject, "err", err.Error())
+			continue
+		}
+		if len(subs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func channelHash(ch *model.Channel) string {
+	h := sha256.Sum256([]byte(ch.Header + "\x00" + ch.Purpose))
+	return fmt.Sprintf("%x", h[:8])
+}
@@ -0,0 +1,122 @@
+package impl
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+)
+
+// testClient records the notifications sent to the Apps.
+type testClient struct {
+	apps.Client
+	mu            sync.Mutex
+	notifications []*apps.Notification
+}
+
+func (c *testClient) PostNotification(n *apps.Notification) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.notifications = append(c.notifications, n)
+	return nil
+}
+
+func (c *testClient) waitForNotifications(t *testing.T, n int) []string {
+	var out []string
+	require.Eventually(t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		out = nil
+		for _, n := range c.notifications {
+			id := n.Context.TeamID
+			if n.Context.ChannelID != "" {
+				id = n.Context.ChannelID
+			}
+			out = append(out, string(n.Subject)+":"+id)
+		}
+		return len(out) == n
+	}, time.Second, 10*time.Millisecond)
+	sort.Strings(out)
+	return out
+}
+
+func TestPollLifecycleChanges(t *testing.T) {
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	mockSubStatusUpdates(mockAPI)
+
+	asJSON := func(v interface{}) []byte {
+		b, _ := json.Marshal(v)
+		return b
+	}
+	subs := asJSON([]*apps.Subscription{{AppID: "app-id"}})
+	mockAPI.On("KVGet", mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "sub_")
+	})).Return(subs, nil)
+
+	mockAPI.On("GetTeams").Return([]*model.Team{
+		{Id: "team1", UpdateAt: 2},
+		{Id: "team2", UpdateAt: 1},
+	}, nil)
+	mockAPI.On("KVGet", keyLifecycleTeams).Return(asJSON(map[string]int64{"team1": 1}), nil)
+
+	unchanged := &model.Channel{Id: "unchanged", TeamId: "team1", Type: model.CHANNEL_OPEN, Header: "header"}
+	updated := &model.Channel{Id: "updated", TeamId: "team1", Type: model.CHANNEL_OPEN, Header: "new header"}
+	restored := &model.Channel{Id: "restored", TeamId: "team1", Type: model.CHANNEL_OPEN}
+	archived := &model.Channel{Id: "archived", TeamId: "team1", Type: model.CHANNEL_OPEN, DeleteAt: 10}
+	mockAPI.On("KVGet", prefixLifecycleChannels+"team1").Return(asJSON(map[string]channelState{
+		"unchanged": {Hash: channelHash(unchanged)},
+		"updated":   {Hash: channelHash(&model.Channel{Header: "old header"})},
+		"restored":  {DeleteAt: 5, Hash: channelHash(restored)},
+		"archived":  {Hash: channelHash(archived)},
+	}), nil)
+	mockAPI.On("GetPublicChannelsForTeam", "team1", 0, lifecycleChannelsPerPage).
+		Return([]*model.Channel{unchanged, updated, restored}, nil)
+	mockAPI.On("GetChannel", "archived").Return(archived, nil)
+
+	// team2 is polled for the first time.
+	mockAPI.On("KVGet", prefixLifecycleChannels+"team2").Return(nil, nil)
+	mockAPI.On("GetPublicChannelsForTeam", "team2", 0, lifecycleChannelsPerPage).
+		Return([]*model.Channel{{Id: "new", TeamId: "team2", Type: model.CHANNEL_OPEN}}, nil)
+
+	saved := map[string][]byte{}
+	mockAPI.On("KVSetWithOptions", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			saved[args.String(0)] = args.Get(1).([]byte)
+		}).
+		Return(true, nil)
+
+	client := &testClient{}
+	s := newTestService(mockAPI)
+	s.Client = client
+
+	err := s.PollLifecycleChanges()
+	require.NoError(t, err)
+
+	require.Equal(t, []string{
+		"channel_archived:archived",
+		"channel_restored:restored",
+		"channel_updated:updated",
+		"team_created:team2",
+		"team_updated:team1",
+	}, client.waitForNotifications(t, 5))
+
+	var teams map[string]int64
+	require.NoError(t, json.Unmarshal(saved[keyLifecycleTeams], &teams))
+	require.Equal(t, map[string]int64{"team1": 2, "team2": 1}, teams)
+
+	var channels map[string]channelState
+	require.NoError(t, json.Unmarshal(saved[prefixLifecycleChannels+"team1"], &channels))
+	require.Equal(t, int64(10), channels["archived"].DeleteAt)
+	require.Equal(t, int64(0), channels["restored"].DeleteAt)
+	require.Equal(t, channelHash(updated), channels["updated"].Hash)
+}
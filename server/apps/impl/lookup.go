@@ -0,0 +1,212 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package impl
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+)
+
+const (
+	// lookupMaxOptions is the most options returned for a lookup, the rest
+	// are dropped.
+	lookupMaxOptions = 100
+
+	lookupCacheTTL        = 30 * time.Second
+	lookupCacheMaxEntries = 10000
+)
+
+// lookupDebounce is the least time between the lookups sent to the App for
+// the same field.
+var lookupDebounce = 200 * time.Millisecond
+
+type lookupCacheEntry struct {
+	options   []apps.SelectOption
+	expiresAt int64
+}
+
+// lookupField is when a lookup was last sent to the App for a user's field,
+// and the lookup that waits to be sent next, if any. superseded is closed when
+// a newer lookup takes its place.
+type lookupField struct {
+	sentAt     time.Time
+	superseded chan struct{}
+}
+
+// lookupCache holds the recent lookup results, by the Call they were made
+// for, and the state of the lookups for each user's field. Both are per node.
+type lookupCache struct {
+	mu      sync.Mutex
+	entries map[string]*lookupCacheEntry
+	fields  map[string]*lookupField
+}
+
+func newLookupCache() *lookupCache {
+	return &lookupCache{
+		entries: map[string]*lookupCacheEntry{},
+		fields:  map[string]*lookupField{},
+	}
+}
+
+// lookupCacheKey covers everything the options may depend on, i.e. what is
+// sent to the App.
+func lookupCacheKey(c *apps.Call) string {
+	data, _ := json.Marshal([]interface{}{
+		c.Context.AppID, c.Context.ActingUserID, c.Context.TeamID, c.Context.ChannelID, c.Context.PostID,
+		c.URL, c.SelectedField, c.Query, c.Values,
+	})
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+func (c *lookupCache) get(key string) ([]apps.SelectOption, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := c.entries[key]
+	if entry == nil || entry.expiresAt <= model.GetMillis() {
+		return nil, false
+	}
+	return entry.options, true
+}
+
+func (c *lookupCache) put(key string, options []apps.SelectOption) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= lookupCacheMaxEntries {
+		c.evict()
+	}
+	c.entries[key] = &lookupCacheEntry{
+		options:   options,
+		expiresAt: model.GetMillis() + lookupCacheTTL.Milliseconds(),
+	}
+}
+
+// evict must be called with the lock held.
+func (c *lookupCache) evict() {
+	now := model.GetMillis()
+	for key, entry := range c.entries {
+		if entry.expiresAt <= now {
+			delete(c.entries, key)
+		}
+	}
+	for key := range c.entries {
+		if len(c.entries) < lookupCacheMaxEntries*9/10 {
+			return
+		}
+		delete(c.entries, key)
+	}
+}
+
+// debounce returns true when the lookup may be sent to the App, and false if a
+// newer lookup for the same field is made first. A lookup is sent right away if
+// none was sent for the field within wait, otherwise it waits for the rest of
+// it. A superseded lookup returns as soon as the newer one is made.
+func (c *lookupCache) debounce(field string, wait time.Duration) bool {
+	c.mu.Lock()
+	if len(c.fields) >= lookupCacheMaxEntries {
+		c.evictFields(wait)
+	}
+	f := c.fields[field]
+	if f == nil {
+		f = &lookupField{}
+		c.fields[field] = f
+	}
+	if f.superseded != nil {
+		close(f.superseded)
+		f.superseded = nil
+	}
+	delay := time.Until(f.sentAt.Add(wait))
+	if delay <= 0 {
+		f.sentAt = time.Now()
+		c.mu.Unlock()
+		return true
+	}
+	superseded := make(chan struct{})
+	f.superseded = superseded
+	c.mu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-superseded:
+		return false
+	case <-timer.C:
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if f.superseded != superseded {
+		return false
+	}
+	f.superseded = nil
+	f.sentAt = time.Now()
+	return true
+}
+
+// evictFields drops the fields that have no lookup waiting, and none sent
+// within wait. It must be called with the lock held.
+func (c *lookupCache) evictFields(wait time.Duration) {
+	for key, f := range c.fields {
+		if f.superseded == nil && time.Since(f.sentAt) >= wait {
+			delete(c.fields, key)
+		}
+	}
+}
+
+// Lookup fetches the options of a dynamic select field from the App, as the
+// user types. The results are cached for a short while, and the lookups made
+// in quick succession for a field are debounced, the superseded ones return
+// apps.ErrLookupSuperseded. The cache and the debouncing are per node, the
+// lookups that are routed to other nodes of a cluster are not coalesced.
+//
+// The lookups are not submits and are not for a binding, so they are posted
+// to the App directly once the user is checked to have access to the context.
+func (s *service) Lookup(c *apps.Call) ([]apps.SelectOption, error) {
+	c.Type = apps.CallTypeLookup
+	key := lookupCacheKey(c)
+	if options, ok := s.lookups.get(key); ok {
+		return options, nil
+	}
+
+	field := fmt.Sprintf("%s|%s|%s|%s", c.Context.AppID, c.Context.ActingUserID, c.URL, c.SelectedField)
+	if !s.lookups.debounce(field, lookupDebounce) {
+		return nil, apps.ErrLookupSuperseded
+	}
+
+	err := s.filterContext(c)
+	if err != nil {
+		return nil, err
+	}
+	cc, err := s.newExpander(c.Context).Expand(c.Expand)
+	if err != nil {
+		return nil, err
+	}
+	clone := *c
+	clone.Context = cc
+	res, err := s.Client.PostCall(&clone)
+	if err != nil {
+		return nil, err
+	}
+	if res.Type == apps.CallResponseTypeError {
+		return nil, errors.New(res.Error)
+	}
+
+	options := res.Options
+	if len(options) > lookupMaxOptions {
+		options = options[:lookupMaxOptions]
+	}
+	if options == nil {
+		options = []apps.SelectOption{}
+	}
+	s.lookups.put(key, options)
+	return options, nil
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package impl
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+)
+
+func TestLookup(t *testing.T) {
+	defer func(debounce time.Duration) { lookupDebounce = debounce }(lookupDebounce)
+	lookupDebounce = 0
+
+	app := &apps.App{
+		Manifest:         &apps.Manifest{AppID: "app"},
+		GrantedLocations: apps.Locations{apps.LocationChannelHeader},
+	}
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	s := newTestService(mockAPI, app)
+	options := []apps.SelectOption{}
+	for i := 0; i < lookupMaxOptions+10; i++ {
+		options = append(options, apps.SelectOption{Label: fmt.Sprintf("Option %v", i), Value: fmt.Sprintf("%v", i)})
+	}
+	client := &testBindingsClient{
+		responses: map[string]*apps.CallResponse{
+			"/options": {Options: options},
+			"/failing": {Type: apps.CallResponseTypeError, Error: "test error"},
+		},
+	}
+	s.Client = client
+	lookup := func(url, query string) ([]apps.SelectOption, error) {
+		return s.Lookup(&apps.Call{
+			URL:           url,
+			Values:        apps.CallValues{"team": "team1"},
+			Context:       &apps.Context{AppID: "app", ActingUserID: "user-id"},
+			SelectedField: "option",
+			Query:         query,
+		})
+	}
+
+	out, err := lookup("/options", "opt")
+	require.NoError(t, err)
+	require.Len(t, out, lookupMaxOptions)
+	require.Len(t, client.posted, 1)
+	call := client.posted[0]
+	require.Equal(t, apps.CallTypeLookup, call.Type)
+	require.Equal(t, "opt", call.Query)
+	require.Equal(t, "option", call.SelectedField)
+	require.Equal(t, apps.CallValues{"team": "team1"}, call.Values)
+	// The bindings are not fetched to check the lookups.
+	require.Empty(t, client.takeCalls())
+
+	// The same lookup is served from the cache.
+	out, err = lookup("/options", "opt")
+	require.NoError(t, err)
+	require.Len(t, out, lookupMaxOptions)
+	require.Len(t, client.posted, 1)
+	_, err = lookup("/options", "opti")
+	require.NoError(t, err)
+	require.Len(t, client.posted, 2)
+
+	_, err = lookup("/failing", "")
+	require.EqualError(t, err, "test error")
+}
+
+func TestLookupDebounce(t *testing.T) {
+	c := newLookupCache()
+	wait := 100 * time.Millisecond
+
+	// The first lookup is not delayed.
+	start := time.Now()
+	require.True(t, c.debounce("field", wait))
+	require.Less(t, int64(time.Since(start)), int64(wait/2))
+
+	// The next one waits for the rest of the interval, and returns as soon as
+	// a newer one supersedes it.
+	results := make(chan bool)
+	go func() {
+		results <- c.debounce("field", wait)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	go func() {
+		results <- c.debounce("field", wait)
+	}()
+	require.False(t, <-results)
+	require.Less(t, int64(time.Since(start)), int64(wait))
+	require.True(t, <-results)
+	require.GreaterOrEqual(t, int64(time.Since(start)), int64(wait))
+
+	// The lookups for other fields do not interfere.
+	start = time.Now()
+	require.True(t, c.debounce("field1", wait))
+	require.True(t, c.debounce("field2", wait))
+	require.Less(t, int64(time.Since(start)), int64(wait/2))
+}
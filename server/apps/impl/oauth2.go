@@ -0,0 +1,193 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package impl
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+
+	pluginapi "github.com/mattermost/mattermost-plugin-api"
+	"github.com/mattermost/mattermost-plugin-api/experimental/bot/logger"
+	"github.com/mattermost/mattermost-plugin-api/experimental/oauther"
+	"github.com/mattermost/mattermost-server/v5/model"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+	"github.com/mattermost/mattermost-plugin-apps/server/utils/md"
+)
+
+const prefixOAuth2 = "o2_"
+
+// errOAuth2NotConnected is returned when an App needs the acting user's OAuth2
+// token, and the user has not yet connected their account to the App.
+var errOAuth2NotConnected = errors.New("user has not connected their account to the app")
+
+// GetOAuther returns the OAuther that handles the Mattermost OAuth2 flow for
+// the App's users. The users' tokens are stored by the plugin, under a prefix
+// unique to the App.
+func (s *service) GetOAuther(appID apps.AppID) (oauther.OAuther, error) {
+	app, err := s.GetApp(appID)
+	if err != nil {
+		return nil, err
+	}
+	if app.OAuth2ClientID == "" {
+		return nil, errors.Errorf("app %s has no OAuth2 client", appID)
+	}
+
+	conf := s.Configurator.GetConfig()
+	return oauther.New(conf.PluginURL,
+		oauth2.Config{
+			ClientID:     app.OAuth2ClientID,
+			ClientSecret: app.OAuth2ClientSecret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  conf.MattermostSiteURL + "/oauth/authorize",
+				TokenURL: conf.MattermostSiteURL + "/oauth/access_token",
+			},
+		},
+		s.newOAuth2OnConnect(appID),
+		&s.Mattermost.KV,
+		logger.New(&logAPI{&s.Mattermost.Log}),
+		oauther.OAuthURL(oauth2Path(appID)),
+		oauther.StorePrefix(oauth2StorePrefix(appID)),
+	), nil
+}
+
+// logAPI adapts the plugin's log to the logger used by the OAuther.
+type logAPI struct {
+	log *pluginapi.LogService
+}
+
+func (l *logAPI) LogError(message string, keyValuePairs ...interface{}) {
+	l.log.Error(message, keyValuePairs...)
+}
+
+func (l *logAPI) LogWarn(message string, keyValuePairs ...interface{}) {
+	l.log.Warn(message, keyValuePairs...)
+}
+
+func (l *logAPI) LogInfo(message string, keyValuePairs ...interface{}) {
+	l.log.Info(message, keyValuePairs...)
+}
+
+func (l *logAPI) LogDebug(message string, keyValuePairs ...interface{}) {
+	l.log.Debug(message, keyValuePairs...)
+}
+
+// StartOAuth2Connect saves callOnComplete, and returns the URL the acting user
+// needs to visit to connect their account to the App. Once connected,
+// callOnComplete is made on the user's behalf.
+func (s *service) StartOAuth2Connect(appID apps.AppID, actingUserID string, callOnComplete *apps.Call) (string, error) {
+	o, err := s.GetOAuther(appID)
+	if err != nil {
+		return "", err
+	}
+
+	// The context will be re-expanded when the call is made.
+	clone := *callOnComplete
+	if clone.Context != nil {
+		cc := *clone.Context
+		cc.ExpandedContext = apps.ExpandedContext{}
+		clone.Context = &cc
+	}
+	payload, err := json.Marshal(clone)
+	if err != nil {
+		return "", err
+	}
+
+	err = o.AddPayload(actingUserID, payload)
+	if err != nil {
+		return "", err
+	}
+	return o.GetConnectURL(), nil
+}
+
+func (s *service) newOAuth2OnConnect(appID apps.AppID) func(string, oauth2.Token, []byte) {
+	return func(userID string, _ oauth2.Token, payload []byte) {
+		if len(payload) == 0 {
+			return
+		}
+		call, err := apps.UnmarshalCallFromData(payload)
+		if err != nil {
+			s.Mattermost.Log.Error("failed to unmarshal the call to continue after OAuth2 connect", "err", err.Error())
+			return
+		}
+		if call.Context == nil {
+			call.Context = &apps.Context{}
+		}
+		call.Context.AppID = appID
+		call.Context.ActingUserID = userID
+
+		message := md.MD("")
+		cr, err := s.API.Call(call)
+		switch {
+		case err != nil:
+			message = md.Markdownf("Failed to continue after connecting your account: %s", err.Error())
+		case cr.Type == apps.CallResponseTypeError:
+			message = md.MD(cr.Error)
+		default:
+			message = cr.Markdown
+		}
+		if message == "" {
+			return
+		}
+
+		app, err := s.GetApp(appID)
+		if err != nil {
+			return
+		}
+		_ = s.Mattermost.Post.DM(app.BotUserID, userID, &model.Post{
+			Message: message.String(),
+		})
+	}
+}
+
+// getOAuth2Token returns the acting user's access token for the App, or
+// errOAuth2NotConnected.
+func (s *service) getOAuth2Token(appID apps.AppID, userID string) (string, error) {
+	o, err := s.GetOAuther(appID)
+	if err != nil {
+		return "", err
+	}
+	token, err := o.GetToken(userID)
+	if err != nil {
+		return "", err
+	}
+	if token == nil || !token.Valid() {
+		return "", errOAuth2NotConnected
+	}
+	return token.AccessToken, nil
+}
+
+func (s *service) newOAuth2ConnectResponse(call *apps.Call) (*apps.CallResponse, error) {
+	connectURL, err := s.StartOAuth2Connect(call.Context.AppID, call.Context.ActingUserID, call)
+	if err != nil {
+		return nil, err
+	}
+	return &apps.CallResponse{
+		Type:          apps.CallResponseTypeNavigate,
+		NavigateToURL: connectURL,
+		Markdown: md.Markdownf(
+			"%s needs to act on your behalf. Please [connect](%s) the application to your Mattermost account.",
+			call.Context.AppID, connectURL),
+	}, nil
+}
+
+func oauth2Path(appID apps.AppID) string {
+	return apps.OAuth2Path + "/" + string(appID)
+}
+
+// oauth2CallbackURL returns the OAuth2 redirect URL for the App's users.
+func (s *service) oauth2CallbackURL(appID apps.AppID) string {
+	return s.Configurator.GetConfig().PluginURL + oauth2Path(appID) + apps.OAuth2CompletePath
+}
+
+// oauth2StorePrefix uses a hash of the App ID to keep the OAuther keys, e.g.
+// "<prefix>payload_<user_id>", within the KV key length limit.
+func oauth2StorePrefix(appID apps.AppID) string {
+	h := sha256.Sum256([]byte(appID))
+	return fmt.Sprintf("%s%x_", prefixOAuth2, h[:6])
+}
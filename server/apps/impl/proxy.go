@@ -4,8 +4,11 @@
 package impl
 
 import (
-	"github.com/mattermost/mattermost-plugin-apps/server/apps"
 	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
 )
 
 func (s *service) filterContext(c *apps.Call) error {
@@ -42,25 +45,103 @@ func (s *service) Call(c *apps.Call) (*apps.CallResponse, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = s.checkCallRoles(c)
+	if err != nil {
+		return nil, err
+	}
+	form := s.getCallForm(c)
+	res, err := s.checkSubmit(c, form)
+	if res != nil || err != nil {
+		return res, err
+	}
 
 	cc, err := s.newExpander(c.Context).Expand(c.Expand)
+	if errors.Cause(err) == errOAuth2NotConnected {
+		return s.newOAuth2ConnectResponse(c)
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	clone := *c
 	clone.Context = cc
-	return s.Client.PostCall(&clone)
+	res, err = s.Client.PostCall(&clone)
+	if err != nil {
+		return nil, err
+	}
+	s.updateCallForm(c, form, res)
+	if res.RefreshBindings {
+		err = s.InvalidateBindings(c.Context.AppID)
+		if err != nil {
+			s.Mattermost.Log.Warn("failed to refresh bindings", "app_id", c.Context.AppID, "err", err.Error())
+		}
+	}
+	return res, nil
 }
 
 func (s *service) Notify(cc *apps.Context, subj apps.Subject) error {
-	subs, err := s.Store.GetSubs(subj, cc.TeamID, cc.ChannelID)
+	expander := s.newExpander(cc)
+
+	// The channel's team is needed to find the team-wide subscriptions, it is
+	// only looked up if there may be any.
+	channelScoped := subj.IsChannelScoped() && cc.ChannelID != ""
+	if channelScoped && cc.TeamID == "" && cc.Channel == nil {
+		hasTeamSubs, err := s.Store.HasTeamSubs(subj)
+		if err != nil {
+			return err
+		}
+		if hasTeamSubs {
+			err = s.expandNotifyChannel(cc)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	subs, err := s.Store.FindSubs(subj, cc.TeamID, cc.ChannelID)
 	if err != nil {
 		return err
 	}
 
-	expander := s.newExpander(cc)
+	// The channel's type is needed to only notify the wide subscriptions
+	// about public channels, and to check the bots' membership. It is kept in
+	// the context for the expansions.
+	if channelScoped && cc.Channel == nil {
+		err = s.expandNotifyChannel(cc)
+		if err != nil {
+			return err
+		}
+	}
+
+	now := model.GetMillis()
 	for _, sub := range subs {
+		if sub.SuspendedAt != 0 || sub.IsExpired(now) {
+			continue
+		}
+		if !s.matchesFilter(sub, cc.Post) {
+			continue
+		}
+		if sub.IsWide() && (cc.Channel == nil || cc.Channel.Type != model.CHANNEL_OPEN) {
+			continue
+		}
+
+		// The App's bot may have been removed from the channel since the App
+		// subscribed, or the event may be about a channel it never joined, e.g.
+		// a new private channel.
+		if cc.Channel != nil && cc.Channel.Type != model.CHANNEL_OPEN {
+			err = s.checkBotChannelMember(sub.AppID, cc.Channel.Id)
+			if err != nil {
+				s.Mattermost.Log.Debug("dropping notification", "app_id", sub.AppID, "subject", subj, "err", err.Error())
+				continue
+			}
+		}
+
+		// The permission may have been revoked since the App subscribed.
+		err = s.checkSubscriptionPermission(sub)
+		if err != nil {
+			s.Mattermost.Log.Debug("skipping notification", "app_id", sub.AppID, "subject", subj, "err", err.Error())
+			continue
+		}
 		req := apps.Notification{
 			Subject: subj,
 			Context: &apps.Context{},
@@ -73,9 +154,19 @@ func (s *service) Notify(cc *apps.Context, subj apps.Subject) error {
 		// Always set the AppID for routing the request to the App
 		req.Context.AppID = sub.AppID
 
-		go func() {
-			_ = s.Client.PostNotification(&req)
-		}()
+		go s.deliverNotification(sub, &req)
+	}
+	return nil
+}
+
+func (s *service) expandNotifyChannel(cc *apps.Context) error {
+	ch, err := s.Mattermost.Channel.Get(cc.ChannelID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get channel %s", cc.ChannelID)
+	}
+	cc.Channel = ch
+	if cc.TeamID == "" {
+		cc.TeamID = ch.TeamId
 	}
 	return nil
 }
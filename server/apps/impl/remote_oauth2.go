@@ -0,0 +1,157 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package impl
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+	"github.com/mattermost/mattermost-plugin-apps/server/utils"
+	"github.com/mattermost/mattermost-plugin-apps/server/utils/md"
+)
+
+// ConfigureRemoteOAuth2 stores the client credentials for a third-party
+// OAuth2 provider declared by the App.
+func (s *service) ConfigureRemoteOAuth2(appID apps.AppID, providerID string, client *apps.RemoteOAuth2Client) error {
+	_, _, err := s.getRemoteOAuth2Provider(appID, providerID)
+	if err != nil {
+		return err
+	}
+	if client.ClientID == "" || client.ClientSecret == "" {
+		return errors.New("client ID and secret must be provided")
+	}
+	return s.Store.StoreRemoteOAuth2Client(appID, providerID, client)
+}
+
+// StartRemoteOAuth2Connect returns the provider's authorization URL for the
+// user to visit. The flow completes at the proxy's callback, see
+// CompleteRemoteOAuth2Connect.
+func (s *service) StartRemoteOAuth2Connect(appID apps.AppID, providerID, userID string) (string, error) {
+	_, provider, err := s.getRemoteOAuth2Provider(appID, providerID)
+	if err != nil {
+		return "", err
+	}
+	client, err := s.Store.GetRemoteOAuth2Client(appID, providerID)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to load the client configuration for %s", providerID)
+	}
+
+	state, err := s.Store.CreateRemoteOAuth2State(&apps.RemoteOAuth2State{
+		AppID:      appID,
+		ProviderID: providerID,
+		UserID:     userID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	conf := provider.OAuth2Config(client, s.remoteOAuth2URL(appID, providerID, apps.RemoteOAuth2CompletePath))
+	return conf.AuthCodeURL(state), nil
+}
+
+// CompleteRemoteOAuth2Connect exchanges the authorization code for a token,
+// and stores it for the user.
+func (s *service) CompleteRemoteOAuth2Connect(appID apps.AppID, providerID, userID, stateID, code string) error {
+	state, err := s.Store.ConsumeRemoteOAuth2State(stateID)
+	if err != nil {
+		return errors.Wrap(err, "invalid state")
+	}
+	if state.AppID != appID || state.ProviderID != providerID || state.UserID != userID {
+		return errors.New("invalid state")
+	}
+
+	app, provider, err := s.getRemoteOAuth2Provider(appID, providerID)
+	if err != nil {
+		return err
+	}
+	client, err := s.Store.GetRemoteOAuth2Client(appID, providerID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load the client configuration for %s", providerID)
+	}
+
+	conf := provider.OAuth2Config(client, s.remoteOAuth2URL(appID, providerID, apps.RemoteOAuth2CompletePath))
+	token, err := conf.Exchange(context.Background(), code)
+	if err != nil {
+		return errors.Wrap(err, "failed to exchange the authorization code")
+	}
+	err = s.Store.StoreRemoteOAuth2Token(appID, providerID, userID, token)
+	if err != nil {
+		return err
+	}
+
+	_ = s.Mattermost.Post.DM(app.BotUserID, userID, &model.Post{
+		Message: md.Markdownf("Connected your %s account to %s.",
+			provider.DisplayName, app.Manifest.DisplayName).String(),
+	})
+	return nil
+}
+
+// getRemoteOAuth2Tokens returns the user's tokens for the App's third-party
+// OAuth2 providers, keyed by the provider ID. Expired tokens are refreshed
+// and stored. Providers that have not been configured yet are omitted.
+func (s *service) getRemoteOAuth2Tokens(app *apps.App, userID string) (map[string]*apps.RemoteOAuth2Token, error) {
+	out := map[string]*apps.RemoteOAuth2Token{}
+	for _, provider := range app.Manifest.RemoteOAuth2Providers {
+		client, err := s.Store.GetRemoteOAuth2Client(app.Manifest.AppID, provider.ID)
+		if err == utils.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load the client configuration for %s", provider.ID)
+		}
+
+		connect := &apps.RemoteOAuth2Token{
+			ConnectURL: s.remoteOAuth2URL(app.Manifest.AppID, provider.ID, apps.RemoteOAuth2ConnectPath),
+		}
+		token, err := s.Store.GetRemoteOAuth2Token(app.Manifest.AppID, provider.ID, userID)
+		if err == utils.ErrNotFound {
+			out[provider.ID] = connect
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load the token for %s", provider.ID)
+		}
+
+		conf := provider.OAuth2Config(client, "")
+		refreshed, err := conf.TokenSource(context.Background(), token).Token()
+		if err != nil {
+			// The refresh token is no longer valid, the user needs to re-connect.
+			s.Mattermost.Log.Debug("failed to refresh OAuth2 token", "app_id", app.Manifest.AppID, "provider", provider.ID, "err", err.Error())
+			out[provider.ID] = connect
+			continue
+		}
+		if refreshed.AccessToken != token.AccessToken {
+			err = s.Store.StoreRemoteOAuth2Token(app.Manifest.AppID, provider.ID, userID, refreshed)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to store the refreshed token for %s", provider.ID)
+			}
+		}
+		out[provider.ID] = &apps.RemoteOAuth2Token{
+			AccessToken: refreshed.AccessToken,
+		}
+	}
+	return out, nil
+}
+
+func (s *service) getRemoteOAuth2Provider(appID apps.AppID, providerID string) (*apps.App, *apps.RemoteOAuth2Provider, error) {
+	app, err := s.GetApp(appID)
+	if err != nil {
+		return nil, nil, err
+	}
+	provider := app.Manifest.GetRemoteOAuth2Provider(providerID)
+	if provider == nil {
+		return nil, nil, errors.Errorf("app %s does not declare OAuth2 provider %s", appID, providerID)
+	}
+	return app, provider, nil
+}
+
+// remoteOAuth2URL returns the proxy's URL for the connect flow, path is
+// RemoteOAuth2ConnectPath or RemoteOAuth2CompletePath.
+func (s *service) remoteOAuth2URL(appID apps.AppID, providerID, path string) string {
+	return s.Configurator.GetConfig().PluginURL + apps.RemoteOAuth2Path + "/" + string(appID) + "/" + providerID + path
+}
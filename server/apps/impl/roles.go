@@ -0,0 +1,133 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package impl
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+)
+
+// roleChecker checks Binding.RoleID against the system, team and channel
+// roles of the acting user in the context. The roles are loaded on first use,
+// so that the bindings that do not require any do not cost the lookups.
+type roleChecker struct {
+	s     *service
+	cc    *apps.Context
+	roles map[string]bool
+}
+
+func (s *service) newRoleChecker(cc *apps.Context) *roleChecker {
+	return &roleChecker{
+		s:  s,
+		cc: cc,
+	}
+}
+
+// has returns true if the acting user has the role. System admins have all
+// roles, and team admins have the channel admin role in their teams. If the
+// roles fail to load, the user is assumed to have none. A nil roleChecker
+// allows all roles.
+func (rc *roleChecker) has(roleID string) bool {
+	if roleID == "" || rc == nil {
+		return true
+	}
+	if rc.roles == nil {
+		rc.roles = rc.s.loadUserRoles(rc.cc)
+	}
+
+	switch {
+	case rc.roles[roleID], rc.roles[model.SYSTEM_ADMIN_ROLE_ID]:
+		return true
+	case roleID == model.CHANNEL_ADMIN_ROLE_ID && rc.roles[model.TEAM_ADMIN_ROLE_ID]:
+		return true
+	}
+	return false
+}
+
+// hasAll returns true if the acting user has all the roles.
+func (rc *roleChecker) hasAll(roleIDs []string) bool {
+	for _, roleID := range roleIDs {
+		if !rc.has(roleID) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *service) loadUserRoles(cc *apps.Context) map[string]bool {
+	roles := map[string]bool{}
+	if cc.ActingUserID == "" {
+		return roles
+	}
+
+	user, err := s.Mattermost.User.Get(cc.ActingUserID)
+	if err != nil {
+		s.Mattermost.Log.Debug("failed to load user roles", "user_id", cc.ActingUserID, "err", err.Error())
+		return roles
+	}
+	addRoles(roles, user.Roles)
+
+	if cc.TeamID != "" {
+		tm, err := s.Mattermost.Team.GetMember(cc.TeamID, cc.ActingUserID)
+		if err == nil {
+			addRoles(roles, tm.Roles)
+			roles[model.TEAM_GUEST_ROLE_ID] = roles[model.TEAM_GUEST_ROLE_ID] || tm.SchemeGuest
+			roles[model.TEAM_USER_ROLE_ID] = roles[model.TEAM_USER_ROLE_ID] || tm.SchemeUser
+			roles[model.TEAM_ADMIN_ROLE_ID] = roles[model.TEAM_ADMIN_ROLE_ID] || tm.SchemeAdmin
+		}
+	}
+
+	if cc.ChannelID != "" {
+		cm, err := s.Mattermost.Channel.GetMember(cc.ChannelID, cc.ActingUserID)
+		if err == nil {
+			addRoles(roles, cm.Roles)
+			roles[model.CHANNEL_GUEST_ROLE_ID] = roles[model.CHANNEL_GUEST_ROLE_ID] || cm.SchemeGuest
+			roles[model.CHANNEL_USER_ROLE_ID] = roles[model.CHANNEL_USER_ROLE_ID] || cm.SchemeUser
+			roles[model.CHANNEL_ADMIN_ROLE_ID] = roles[model.CHANNEL_ADMIN_ROLE_ID] || cm.SchemeAdmin
+		}
+	}
+
+	return roles
+}
+
+func addRoles(roles map[string]bool, names string) {
+	for _, name := range strings.Fields(names) {
+		roles[name] = true
+	}
+}
+
+// checkCallRoles rejects the Calls to a binding that is hidden from the acting
+// user by its RoleID, so that hidden actions can not be triggered by calling
+// their URL directly. The Call is checked against the RoleIDs learned when the
+// App's bindings were fetched by this node, see bindingsInfo. If this node has
+// not fetched them yet, they are fetched for the Call's context, and the Call
+// is rejected if that fails. Calls to URLs that are not bound are not
+// affected.
+func (s *service) checkCallRoles(c *apps.Call) error {
+	appID := c.Context.AppID
+	if _, known := s.bindingsInfo.Load(appID); !known {
+		_, err := s.fetchContextBindings(c.Context)
+		if err != nil {
+			return errors.Wrap(err, "failed to get the bindings to check the call")
+		}
+	}
+
+	callRoles := s.getBindingsInfo(appID).callRoles[c.URL]
+	if len(callRoles) == 0 {
+		return nil
+	}
+
+	roles := s.newRoleChecker(c.Context)
+	for _, roleIDs := range callRoles {
+		if roles.hasAll(roleIDs) {
+			return nil
+		}
+	}
+	return errors.Errorf("%s is not allowed for the user's roles", c.URL)
+}
@@ -18,6 +18,19 @@ type service struct {
 	Store store.Service
 
 	appsCache *sync.Map
+
+	// bindingsErrors has the last error fetching the bindings, by AppID. It is
+	// per node, the other nodes of a cluster have their own.
+	bindingsErrors *sync.Map
+	bindingsCache  *bindingsCache
+
+	// bindingsInfo has what was learned about the Apps' bindings, by AppID.
+	// bindingsInfoMutex serializes its updates.
+	bindingsInfo      *sync.Map
+	bindingsInfoMutex sync.Mutex
+
+	commands *commandRegistry
+	lookups  *lookupCache
 }
 
 func NewService(mm *pluginapi.Client, configurator configurator.Service) *apps.Service {
@@ -26,8 +39,13 @@ func NewService(mm *pluginapi.Client, configurator configurator.Service) *apps.S
 			Configurator: configurator,
 			Mattermost:   mm,
 		},
-		appsCache: &sync.Map{},
-		Store:     store.NewService(mm, configurator),
+		appsCache:      &sync.Map{},
+		bindingsErrors: &sync.Map{},
+		bindingsCache:  newBindingsCache(),
+		bindingsInfo:   &sync.Map{},
+		commands:       newCommandRegistry(),
+		lookups:        newLookupCache(),
+		Store:          store.NewService(mm, configurator),
 	}
 	s.Client = s.newClient()
 	s.API = s
@@ -0,0 +1,109 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package impl
+
+import (
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+)
+
+const (
+	// subscriptionExpiringNotice is how long before a subscription expires
+	// that the App is sent SubjectSubscriptionExpiring.
+	subscriptionExpiringNotice = 24 * time.Hour
+
+	// defaultSubscriptionSuspendAfter is how long the deliveries of a
+	// subscription may keep failing before it is suspended, unless configured.
+	defaultSubscriptionSuspendAfter = 3 * 24 * time.Hour
+)
+
+// deliverNotification posts the notification to the App, and keeps track of
+// the subscription's failing deliveries.
+func (s *service) deliverNotification(sub *apps.Subscription, n *apps.Notification) {
+	err := s.Client.PostNotification(n)
+	if err != nil {
+		s.Mattermost.Log.Debug("failed to deliver notification", "app_id", sub.AppID, "subject", n.Subject, "err", err.Error())
+		err = s.Store.RecordSubDeliveryFailure(sub, model.GetMillis())
+	} else {
+		err = s.Store.ResetSubDeliveryFailures(sub)
+	}
+	if err != nil {
+		s.Mattermost.Log.Warn("failed to update subscription delivery status", "app_id", sub.AppID, "subject", n.Subject, "err", err.Error())
+	}
+}
+
+// CheckSubscriptions purges the expired subscriptions, tells the Apps about
+// the ones that are about to expire, and suspends the ones whose deliveries
+// have been failing for too long.
+func (s *service) CheckSubscriptions() error {
+	now := model.GetMillis()
+	suspendAfter := s.subscriptionSuspendAfter()
+	for _, app := range s.ListApps() {
+		appID := app.Manifest.AppID
+		subs, err := s.Store.ListSubs(appID)
+		if err != nil {
+			s.Mattermost.Log.Warn("failed to list subscriptions", "app_id", appID, "err", err.Error())
+			continue
+		}
+		for _, sub := range subs {
+			err = s.checkSubscription(sub, now, suspendAfter)
+			if err != nil {
+				s.Mattermost.Log.Warn("failed to check subscription", "app_id", appID, "subject", sub.Subject, "err", err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// subscriptionSuspendAfter returns how long the deliveries of a subscription
+// may keep failing before it is suspended.
+func (s *service) subscriptionSuspendAfter() time.Duration {
+	conf := s.Configurator.GetConfig()
+	if conf.StoredConfig == nil || conf.SubscriptionSuspendAfterHours <= 0 {
+		return defaultSubscriptionSuspendAfter
+	}
+	return time.Duration(conf.SubscriptionSuspendAfterHours) * time.Hour
+}
+
+func (s *service) checkSubscription(sub *apps.Subscription, now int64, suspendAfter time.Duration) error {
+	if sub.IsExpired(now) {
+		err := s.Store.DeleteSub(sub)
+		if err != nil {
+			return err
+		}
+		return s.Store.ResetSubDeliveryFailures(sub)
+	}
+
+	if sub.ExpiresAt != 0 && time.Duration(sub.ExpiresAt-now)*time.Millisecond <= subscriptionExpiringNotice {
+		first, err := s.Store.MarkSubExpiring(sub, subscriptionExpiringNotice)
+		if err != nil {
+			return err
+		}
+		if first {
+			err = s.Client.PostNotification(&apps.Notification{
+				Subject:      apps.SubjectSubscriptionExpiring,
+				Context:      &apps.Context{AppID: sub.AppID},
+				Subscription: sub,
+			})
+			if err != nil {
+				s.Mattermost.Log.Debug("failed to notify subscription expiring", "app_id", sub.AppID, "subject", sub.Subject, "err", err.Error())
+			}
+		}
+	}
+
+	if sub.SuspendedAt == 0 {
+		since, err := s.Store.GetSubFailingSince(sub)
+		if err != nil {
+			return err
+		}
+		if since != 0 && time.Duration(now-since)*time.Millisecond >= suspendAfter {
+			s.Mattermost.Log.Info("suspending subscription, deliveries have been failing", "app_id", sub.AppID, "subject", sub.Subject)
+			return s.Store.SuspendSub(sub, now)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package impl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+	"github.com/mattermost/mattermost-plugin-apps/server/apps/store"
+)
+
+// testSubsStore keeps the subscription status in memory, subscriptions are
+// identified by their ChannelID.
+type testSubsStore struct {
+	store.Service
+	subs         []*apps.Subscription
+	failingSince map[string]int64
+	expiring     map[string]bool
+	deleted      []string
+	suspended    []string
+	listErrs     map[apps.AppID]error
+}
+
+func (s *testSubsStore) ListSubs(appID apps.AppID) ([]*apps.Subscription, error) {
+	if err := s.listErrs[appID]; err != nil {
+		return nil, err
+	}
+	var out []*apps.Subscription
+	for _, sub := range s.subs {
+		if sub.AppID == appID {
+			out = append(out, sub)
+		}
+	}
+	return out, nil
+}
+
+func (s *testSubsStore) DeleteSub(sub *apps.Subscription) error {
+	s.deleted = append(s.deleted, sub.ChannelID)
+	return nil
+}
+
+func (s *testSubsStore) SuspendSub(sub *apps.Subscription, at int64) error {
+	s.suspended = append(s.suspended, sub.ChannelID)
+	sub.SuspendedAt = at
+	return nil
+}
+
+func (s *testSubsStore) GetSubFailingSince(sub *apps.Subscription) (int64, error) {
+	return s.failingSince[sub.ChannelID], nil
+}
+
+func (s *testSubsStore) ResetSubDeliveryFailures(sub *apps.Subscription) error {
+	delete(s.failingSince, sub.ChannelID)
+	return nil
+}
+
+func (s *testSubsStore) MarkSubExpiring(sub *apps.Subscription, ttl time.Duration) (bool, error) {
+	if s.expiring[sub.ChannelID] {
+		return false, nil
+	}
+	s.expiring[sub.ChannelID] = true
+	return true, nil
+}
+
+func TestCheckSubscriptions(t *testing.T) {
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	mockAPI.On("LogInfo", "suspending subscription, deliveries have been failing", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Once()
+
+	s := newTestService(mockAPI, &apps.App{Manifest: &apps.Manifest{AppID: "app-id"}})
+	client := &testClient{}
+	s.Client = client
+
+	now := model.GetMillis()
+	day := (24 * time.Hour).Milliseconds()
+	newSub := func(id string, expiresAt, suspendedAt int64) *apps.Subscription {
+		return &apps.Subscription{
+			AppID:       "app-id",
+			Subject:     apps.SubjectPostCreated,
+			ChannelID:   id,
+			ExpiresAt:   expiresAt,
+			SuspendedAt: suspendedAt,
+		}
+	}
+	subsStore := &testSubsStore{
+		subs: []*apps.Subscription{
+			newSub("expired", now-1000, 0),
+			newSub("expiring", now+day/2, 0),
+			newSub("not-expiring-yet", now+30*day, 0),
+			newSub("failing", 0, 0),
+			newSub("flaky", 0, 0),
+			newSub("suspended", 0, now-day),
+		},
+		failingSince: map[string]int64{
+			"failing":   now - 4*day,
+			"flaky":     now - day/24,
+			"suspended": now - 10*day,
+		},
+		expiring: map[string]bool{},
+	}
+	s.Store = subsStore
+
+	require.NoError(t, s.CheckSubscriptions())
+	require.Equal(t, []string{"expired"}, subsStore.deleted)
+	require.Equal(t, []string{"failing"}, subsStore.suspended)
+	require.Len(t, client.notifications, 1)
+	n := client.notifications[0]
+	require.Equal(t, apps.SubjectSubscriptionExpiring, n.Subject)
+	require.Equal(t, apps.AppID("app-id"), n.Context.AppID)
+	require.Equal(t, "expiring", n.Subscription.ChannelID)
+
+	// The App is only told once about an expiring subscription.
+	subsStore.subs = subsStore.subs[1:]
+	require.NoError(t, s.CheckSubscriptions())
+	require.Len(t, client.notifications, 1)
+	require.Equal(t, []string{"failing"}, subsStore.suspended)
+}
+
+func TestCheckSubscriptionsSettings(t *testing.T) {
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	mockAPI.On("LogWarn", "failed to list subscriptions", "app_id", apps.AppID("broken"), "err", "list failed").Once()
+	mockAPI.On("LogInfo", "suspending subscription, deliveries have been failing", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Once()
+
+	s := newTestService(mockAPI,
+		&apps.App{Manifest: &apps.Manifest{AppID: "broken"}},
+		&apps.App{Manifest: &apps.Manifest{AppID: "app-id"}},
+	)
+	s.Configurator.GetConfig().SubscriptionSuspendAfterHours = 1
+
+	now := model.GetMillis()
+	hour := time.Hour.Milliseconds()
+	subsStore := &testSubsStore{
+		subs: []*apps.Subscription{
+			{AppID: "app-id", Subject: apps.SubjectPostCreated, ChannelID: "failing"},
+			{AppID: "app-id", Subject: apps.SubjectPostCreated, ChannelID: "flaky"},
+		},
+		failingSince: map[string]int64{
+			"failing": now - 2*hour,
+			"flaky":   now - hour/2,
+		},
+		listErrs: map[apps.AppID]error{
+			"broken": errors.New("list failed"),
+		},
+	}
+	s.Store = subsStore
+
+	// The other Apps' subscriptions are still checked, with the configured
+	// suspension delay.
+	require.NoError(t, s.CheckSubscriptions())
+	require.Equal(t, []string{"failing"}, subsStore.suspended)
+}
+
+func TestSubscribeExpiresAt(t *testing.T) {
+	s := newTestService(&plugintest.API{})
+
+	err := s.Subscribe(&apps.Subscription{
+		AppID:     "app-id",
+		Subject:   apps.SubjectPostCreated,
+		ChannelID: "channel-id",
+		ExpiresAt: model.GetMillis() - 1000,
+	})
+	require.EqualError(t, err, "expires_at must be in the future")
+
+	err = s.Subscribe(&apps.Subscription{
+		AppID:   "app-id",
+		Subject: apps.SubjectSubscriptionExpiring,
+	})
+	require.EqualError(t, err, "subscription_expiring can not be subscribed to")
+}
@@ -4,13 +4,133 @@
 package impl
 
 import (
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+
 	"github.com/mattermost/mattermost-plugin-apps/server/apps"
 )
 
 func (s *service) Subscribe(sub *apps.Subscription) error {
-	return s.Store.StoreSub(sub)
+	if sub.Subject == apps.SubjectSubscriptionExpiring {
+		return errors.Errorf("%s can not be subscribed to", sub.Subject)
+	}
+	if !sub.Subject.IsSupported() {
+		return errors.Errorf("%s is not supported by this Mattermost server", sub.Subject)
+	}
+	err := s.checkSubscriptionPermission(sub)
+	if err != nil {
+		return err
+	}
+	if sub.Filter != nil {
+		err = sub.Filter.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	if sub.IsExpired(model.GetMillis()) {
+		return errors.New("expires_at must be in the future")
+	}
+	err = s.checkSubscriptionScope(sub)
+	if err != nil {
+		return err
+	}
+
+	// Subscribing again renews, and resumes a suspended subscription.
+	sub.SuspendedAt = 0
+	err = s.Store.StoreSub(sub)
+	if err != nil {
+		return err
+	}
+	return s.Store.ResetSubDeliveryFailures(sub)
+}
+
+// matchesFilter evaluates the subscription's post filter, if any.
+func (s *service) matchesFilter(sub *apps.Subscription, post *model.Post) bool {
+	if sub.Filter == nil || post == nil {
+		return true
+	}
+	botUserID := ""
+	if app, err := s.GetApp(sub.AppID); err == nil {
+		botUserID = app.BotUserID
+	}
+	return sub.Filter.Matches(post, botUserID)
+}
+
+// checkSubscriptionPermission checks that the App is granted the permissions
+// that the subscription requires, e.g. since it exposes user data, or is
+// team-wide.
+func (s *service) checkSubscriptionPermission(sub *apps.Subscription) error {
+	permissions := sub.RequiredPermissions()
+	if len(permissions) == 0 {
+		return nil
+	}
+	app, err := s.GetApp(sub.AppID)
+	if err != nil {
+		return err
+	}
+	for _, permission := range permissions {
+		if !app.GrantedPermissions.Contains(permission) {
+			return errors.Errorf("app %s is not granted permission %s", sub.AppID, permission)
+		}
+	}
+	return nil
+}
+
+// checkSubscriptionScope checks that the subscription's channel and team
+// exist, and that the App's bot is a member of the channel unless it is
+// public.
+func (s *service) checkSubscriptionScope(sub *apps.Subscription) error {
+	if sub.ChannelID != "" {
+		ch, err := s.Mattermost.Channel.Get(sub.ChannelID)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get channel %s", sub.ChannelID)
+		}
+		if sub.TeamID != "" && ch.TeamId != sub.TeamID {
+			return errors.Errorf("channel %s is not in team %s", sub.ChannelID, sub.TeamID)
+		}
+		if ch.Type != model.CHANNEL_OPEN {
+			return s.checkBotChannelMember(sub.AppID, ch.Id)
+		}
+		return nil
+	}
+
+	if sub.TeamID != "" {
+		_, err := s.Mattermost.Team.Get(sub.TeamID)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get team %s", sub.TeamID)
+		}
+	}
+	return nil
+}
+
+func (s *service) checkBotChannelMember(appID apps.AppID, channelID string) error {
+	app, err := s.GetApp(appID)
+	if err != nil {
+		return err
+	}
+	if app.BotUserID == "" {
+		return errors.Errorf("app %s has no bot user", appID)
+	}
+	_, err = s.Mattermost.Channel.GetMember(channelID, app.BotUserID)
+	if err != nil {
+		return errors.Wrapf(err, "app %s's bot is not a member of channel %s", appID, channelID)
+	}
+	return nil
 }
 
 func (s *service) Unsubscribe(sub *apps.Subscription) error {
 	return s.Store.DeleteSub(sub)
 }
+
+func (s *service) ListSubscriptions(appID apps.AppID) ([]*apps.Subscription, error) {
+	return s.Store.ListSubs(appID)
+}
+
+func (s *service) UnsubscribeAll(appID apps.AppID) error {
+	return s.Store.DeleteAllSubs(appID)
+}
+
+func (s *service) MigrateAppSubsIndex() error {
+	return s.Store.MigrateAppSubsIndex()
+}
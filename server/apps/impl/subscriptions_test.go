@@ -0,0 +1,311 @@
+package impl
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+	"github.com/mattermost/mattermost-plugin-apps/server/utils"
+)
+
+// mockAppSubsIndexUpdate expects a new subscription to be added to the
+// App's index.
+func mockAppSubsIndexUpdate(mockAPI *plugintest.API) {
+	isIndexKey := mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "suba_")
+	})
+	mockAPI.On("KVGet", isIndexKey).Return(nil, nil).Once()
+	mockAPI.On("KVSetWithOptions", isIndexKey, mock.Anything, mock.Anything).Return(true, nil).Once()
+}
+
+// mockSubStatusUpdates allows the subscriptions' delivery failures to be
+// recorded or reset. The deliveries are asynchronous, so it may not have
+// happened yet when the test ends.
+func mockSubStatusUpdates(mockAPI *plugintest.API) {
+	isStatusKey := mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "subf_")
+	})
+	mockAPI.On("KVGet", isStatusKey).Return(nil, nil).Maybe()
+	mockAPI.On("KVSetWithOptions", isStatusKey, mock.Anything, mock.Anything).Return(true, nil).Maybe()
+}
+
+func TestSubscribeUserPermission(t *testing.T) {
+	granted := &apps.App{
+		Manifest:           &apps.Manifest{AppID: "granted"},
+		GrantedPermissions: apps.Permissions{apps.PermissionUserNotification},
+	}
+	notGranted := &apps.App{
+		Manifest: &apps.Manifest{AppID: "not-granted"},
+	}
+
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	mockSubStatusUpdates(mockAPI)
+	s := newTestService(mockAPI, granted, notGranted)
+
+	err := s.Subscribe(&apps.Subscription{AppID: "not-granted", Subject: apps.SubjectUserCreated})
+	require.EqualError(t, err, "app not-granted is not granted permission user_notification")
+
+	// There is no source of user updates, the subject is not advertised.
+	err = s.Subscribe(&apps.Subscription{AppID: "granted", Subject: apps.SubjectUserUpdated})
+	require.EqualError(t, err, "user_updated is not supported by this Mattermost server")
+
+	mockAPI.On("KVGet", "sub_user_created").Return(nil, nil).Once()
+	mockAPI.On("KVSetWithOptions", "sub_user_created", mock.Anything, mock.Anything).Return(true, nil).Once()
+	mockAppSubsIndexUpdate(mockAPI)
+	err = s.Subscribe(&apps.Subscription{AppID: "granted", Subject: apps.SubjectUserCreated})
+	require.NoError(t, err)
+
+	// Subscriptions of Apps that are no longer granted the permission are
+	// skipped.
+	subs, _ := json.Marshal([]*apps.Subscription{
+		{AppID: "granted", Subject: apps.SubjectUserCreated},
+		{AppID: "not-granted", Subject: apps.SubjectUserCreated},
+	})
+	mockAPI.On("KVGet", "sub_user_created").Return(subs, nil).Once()
+	mockAPI.On("LogDebug", "skipping notification", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Once()
+	client := &testClient{}
+	s.Client = client
+
+	err = s.Notify(apps.NewUserContext(&model.User{Id: "user-id", Username: "new"}), apps.SubjectUserCreated)
+	require.NoError(t, err)
+	client.waitForNotifications(t, 1)
+	require.Equal(t, apps.AppID("granted"), client.notifications[0].Context.AppID)
+}
+
+func TestSubscribeUnsupported(t *testing.T) {
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	s := newTestService(mockAPI, &apps.App{Manifest: &apps.Manifest{AppID: "app-id"}})
+
+	for _, subject := range []apps.Subject{apps.SubjectPostDeleted, apps.SubjectReactionAdded, apps.SubjectReactionRemoved} {
+		err := s.Subscribe(&apps.Subscription{AppID: "app-id", Subject: subject, ChannelID: "channel-id"})
+		require.EqualError(t, err, string(subject)+" is not supported by this Mattermost server")
+	}
+}
+
+func TestNotifyFilter(t *testing.T) {
+	app := &apps.App{
+		Manifest:  &apps.Manifest{AppID: "app-id"},
+		BotUserID: "bot-user-id",
+	}
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	mockSubStatusUpdates(mockAPI)
+	s := newTestService(mockAPI, app)
+	client := &testClient{}
+	s.Client = client
+
+	// Only the unfiltered subscription expands the channel.
+	subs, _ := json.Marshal([]*apps.Subscription{
+		{AppID: "app-id", Subject: apps.SubjectPostCreated, ChannelID: "channel-id",
+			Filter: &apps.PostFilter{ExcludeOwnBot: true}},
+		{AppID: "other-app-id", Subject: apps.SubjectPostCreated, ChannelID: "channel-id",
+			Expand: &apps.Expand{Channel: apps.ExpandAll}},
+	})
+	mockAPI.On("KVGet", "subt_post_created").Return(nil, nil).Once()
+	mockAPI.On("KVGet", "sub_post_created_channel-id").Return(subs, nil).Once()
+	mockAPI.On("KVGet", "sub_post_created").Return(nil, nil).Once()
+	mockAPI.On("GetChannel", "channel-id").Return(&model.Channel{Id: "channel-id", TeamId: "team-id", Type: model.CHANNEL_OPEN}, nil).Once()
+
+	err := s.Notify(apps.NewPostContext(&model.Post{
+		Id:        "post-id",
+		ChannelId: "channel-id",
+		UserId:    "bot-user-id",
+	}), apps.SubjectPostCreated)
+	require.NoError(t, err)
+	client.waitForNotifications(t, 1)
+	require.Equal(t, apps.AppID("other-app-id"), client.notifications[0].Context.AppID)
+	require.NotNil(t, client.notifications[0].Context.Channel)
+}
+
+func TestNotifyNoSubscriptions(t *testing.T) {
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	s := newTestService(mockAPI)
+
+	// The channel is not looked up when no App is subscribed.
+	mockAPI.On("KVGet", "subt_post_created").Return(nil, nil).Once()
+	mockAPI.On("KVGet", "sub_post_created_channel-id").Return(nil, nil).Once()
+	mockAPI.On("KVGet", "sub_post_created").Return(nil, nil).Once()
+
+	err := s.Notify(apps.NewPostContext(&model.Post{Id: "post-id", ChannelId: "channel-id"}), apps.SubjectPostCreated)
+	require.Equal(t, utils.ErrNotFound, err)
+}
+
+func TestWideSubscriptions(t *testing.T) {
+	granted := &apps.App{
+		Manifest:           &apps.Manifest{AppID: "granted"},
+		GrantedPermissions: apps.Permissions{apps.PermissionTeamWideNotification},
+	}
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	mockSubStatusUpdates(mockAPI)
+	s := newTestService(mockAPI, granted)
+	client := &testClient{}
+	s.Client = client
+
+	err := s.Subscribe(&apps.Subscription{AppID: "granted", Subject: apps.SubjectPostCreated})
+	require.EqualError(t, err, "app granted is not granted permission server_wide_notification")
+
+	mockAPI.On("GetTeam", "team-id").Return(&model.Team{Id: "team-id"}, nil).Once()
+	mockAPI.On("KVGet", "sub_post_created_t_team-id").Return(nil, nil).Once()
+	mockAPI.On("KVSetWithOptions", "sub_post_created_t_team-id", mock.Anything, mock.Anything).Return(true, nil).Once()
+	mockAPI.On("KVSetWithOptions", "subt_post_created", []byte("true"), mock.Anything).Return(true, nil).Once()
+	mockAppSubsIndexUpdate(mockAPI)
+	err = s.Subscribe(&apps.Subscription{AppID: "granted", Subject: apps.SubjectPostCreated, TeamID: "team-id"})
+	require.NoError(t, err)
+
+	subs, _ := json.Marshal([]*apps.Subscription{
+		{AppID: "granted", Subject: apps.SubjectPostCreated, TeamID: "team-id"},
+	})
+	for _, ch := range []*model.Channel{
+		{Id: "private", TeamId: "team-id", Type: model.CHANNEL_PRIVATE},
+		{Id: "public", TeamId: "team-id", Type: model.CHANNEL_OPEN},
+	} {
+		mockAPI.On("KVGet", "subt_post_created").Return([]byte("true"), nil).Once()
+		mockAPI.On("GetChannel", ch.Id).Return(ch, nil).Once()
+		mockAPI.On("KVGet", "sub_post_created_"+ch.Id).Return(nil, nil).Once()
+		mockAPI.On("KVGet", "sub_post_created_t_team-id").Return(subs, nil).Once()
+		mockAPI.On("KVGet", "sub_post_created").Return(nil, nil).Once()
+		err = s.Notify(apps.NewPostContext(&model.Post{Id: "post-id", ChannelId: ch.Id}), apps.SubjectPostCreated)
+		require.NoError(t, err)
+	}
+
+	// Only the post in the public channel is notified.
+	require.Equal(t, []string{"post_created:public"}, client.waitForNotifications(t, 1))
+	require.Equal(t, "team-id", client.notifications[0].Context.TeamID)
+}
+
+func TestSubscribeScope(t *testing.T) {
+	app := &apps.App{
+		Manifest:  &apps.Manifest{AppID: "app-id"},
+		BotUserID: "bot-user-id",
+	}
+	public := &model.Channel{Id: "public", TeamId: "team-id", Type: model.CHANNEL_OPEN}
+	private := &model.Channel{Id: "private", TeamId: "team-id", Type: model.CHANNEL_PRIVATE}
+	notFound := &model.AppError{Message: "not found", StatusCode: http.StatusNotFound}
+
+	for name, tc := range map[string]struct {
+		sub           *apps.Subscription
+		setup         func(*plugintest.API)
+		expectedError string
+	}{
+		"public channel": {
+			sub: &apps.Subscription{Subject: apps.SubjectPostCreated, ChannelID: "public", TeamID: "team-id"},
+			setup: func(mockAPI *plugintest.API) {
+				mockAPI.On("GetChannel", "public").Return(public, nil).Once()
+			},
+		},
+		"private channel with the bot": {
+			sub: &apps.Subscription{Subject: apps.SubjectPostCreated, ChannelID: "private"},
+			setup: func(mockAPI *plugintest.API) {
+				mockAPI.On("GetChannel", "private").Return(private, nil).Once()
+				mockAPI.On("GetChannelMember", "private", "bot-user-id").Return(&model.ChannelMember{}, nil).Once()
+			},
+		},
+		"private channel without the bot": {
+			sub: &apps.Subscription{Subject: apps.SubjectPostCreated, ChannelID: "private"},
+			setup: func(mockAPI *plugintest.API) {
+				mockAPI.On("GetChannel", "private").Return(private, nil).Once()
+				mockAPI.On("GetChannelMember", "private", "bot-user-id").Return(nil, notFound).Once()
+			},
+			expectedError: "app app-id's bot is not a member of channel private: not found",
+		},
+		"channel not found": {
+			sub: &apps.Subscription{Subject: apps.SubjectPostCreated, ChannelID: "missing"},
+			setup: func(mockAPI *plugintest.API) {
+				mockAPI.On("GetChannel", "missing").Return(nil, notFound).Once()
+			},
+			expectedError: "failed to get channel missing: not found",
+		},
+		"channel in another team": {
+			sub: &apps.Subscription{Subject: apps.SubjectPostCreated, ChannelID: "public", TeamID: "other-team-id"},
+			setup: func(mockAPI *plugintest.API) {
+				mockAPI.On("GetChannel", "public").Return(public, nil).Once()
+			},
+			expectedError: "channel public is not in team other-team-id",
+		},
+		"team": {
+			sub: &apps.Subscription{Subject: apps.SubjectUserJoinedTeam, TeamID: "team-id"},
+			setup: func(mockAPI *plugintest.API) {
+				mockAPI.On("GetTeam", "team-id").Return(&model.Team{Id: "team-id"}, nil).Once()
+			},
+		},
+		"team not found": {
+			sub: &apps.Subscription{Subject: apps.SubjectUserJoinedTeam, TeamID: "missing"},
+			setup: func(mockAPI *plugintest.API) {
+				mockAPI.On("GetTeam", "missing").Return(nil, notFound).Once()
+			},
+			expectedError: "failed to get team missing: not found",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mockAPI := &plugintest.API{}
+			defer mockAPI.AssertExpectations(t)
+			s := newTestService(mockAPI, app)
+			tc.setup(mockAPI)
+			if tc.expectedError == "" {
+				isSubsKey := mock.MatchedBy(func(key string) bool {
+					return strings.HasPrefix(key, "sub_")
+				})
+				mockAPI.On("KVGet", isSubsKey).Return(nil, nil).Once()
+				mockAPI.On("KVSetWithOptions", isSubsKey, mock.Anything, mock.Anything).Return(true, nil).Once()
+				mockAppSubsIndexUpdate(mockAPI)
+				mockSubStatusUpdates(mockAPI)
+			}
+
+			tc.sub.AppID = app.Manifest.AppID
+			err := s.Subscribe(tc.sub)
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestNotifyPrivateChannel(t *testing.T) {
+	member := &apps.App{
+		Manifest:  &apps.Manifest{AppID: "member"},
+		BotUserID: "member-bot-id",
+	}
+	removed := &apps.App{
+		Manifest:  &apps.Manifest{AppID: "removed"},
+		BotUserID: "removed-bot-id",
+	}
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	mockSubStatusUpdates(mockAPI)
+	s := newTestService(mockAPI, member, removed)
+	client := &testClient{}
+	s.Client = client
+
+	subs, _ := json.Marshal([]*apps.Subscription{
+		{AppID: "member", Subject: apps.SubjectPostCreated, ChannelID: "channel-id"},
+		{AppID: "removed", Subject: apps.SubjectPostCreated, ChannelID: "channel-id"},
+		// Filtered out before the bot's membership is checked.
+		{AppID: "filtered", Subject: apps.SubjectPostCreated, ChannelID: "channel-id",
+			Filter: &apps.PostFilter{Keywords: []string{"deploy"}}},
+	})
+	mockAPI.On("GetChannel", "channel-id").Return(&model.Channel{Id: "channel-id", TeamId: "team-id", Type: model.CHANNEL_PRIVATE}, nil).Once()
+	mockAPI.On("KVGet", "subt_post_created").Return(nil, nil).Once()
+	mockAPI.On("KVGet", "sub_post_created_channel-id").Return(subs, nil).Once()
+	mockAPI.On("KVGet", "sub_post_created").Return(nil, nil).Once()
+	mockAPI.On("GetChannelMember", "channel-id", "member-bot-id").Return(&model.ChannelMember{}, nil).Once()
+	mockAPI.On("GetChannelMember", "channel-id", "removed-bot-id").Return(nil, &model.AppError{Message: "not found"}).Once()
+	mockAPI.On("LogDebug", "dropping notification", "app_id", apps.AppID("removed"), mock.Anything, mock.Anything, mock.Anything, mock.Anything).Once()
+
+	err := s.Notify(apps.NewPostContext(&model.Post{Id: "post-id", ChannelId: "channel-id"}), apps.SubjectPostCreated)
+	require.NoError(t, err)
+	require.Equal(t, []string{"post_created:channel-id"}, client.waitForNotifications(t, 1))
+	require.Equal(t, apps.AppID("member"), client.notifications[0].Context.AppID)
+}
@@ -0,0 +1,35 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package impl
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+)
+
+// UninstallApp removes the App's subscriptions and commands, and then the
+// App itself.
+func (s *service) UninstallApp(appID apps.AppID) error {
+	_, err := s.GetApp(appID)
+	if err != nil {
+		return err
+	}
+
+	err = s.UnsubscribeAll(appID)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete the subscriptions")
+	}
+
+	s.updateAppCommands(appID, nil)
+
+	err = s.DeleteApp(appID)
+	if err != nil {
+		return err
+	}
+
+	s.bindingsInfo.Delete(appID)
+	s.bindingsErrors.Delete(appID)
+	return s.InvalidateBindings(appID)
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package impl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+	"github.com/mattermost/mattermost-plugin-apps/server/utils"
+)
+
+func TestUninstallApp(t *testing.T) {
+	app := &apps.App{
+		Manifest:         &apps.Manifest{AppID: "app"},
+		GrantedLocations: apps.Locations{apps.LocationCommand},
+	}
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	s := newTestService(mockAPI, app)
+	s.Client = &testBindingsClient{
+		bindings: map[apps.AppID][]*apps.Binding{
+			"app": testCommandBindings("hello"),
+		},
+	}
+	mockAPI.On("RegisterCommand", mock.Anything).Return(nil).Once()
+	require.NoError(t, s.registerAppCommands(app))
+
+	// The App's subscriptions are deleted with the App.
+	isIndexKey := mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "suba_")
+	})
+	mockAPI.On("KVGet", isIndexKey).Return([]byte(`["sub_user_created"]`), nil).Once()
+	mockAPI.On("KVGet", "sub_user_created").Return([]byte(`[{"subject":"user_created","app_id":"app"}]`), nil).Once()
+	mockAPI.On("KVSetWithOptions", "sub_user_created", []byte("[]"), mock.Anything).Return(true, nil).Once()
+	mockAPI.On("KVSetWithOptions", isIndexKey, []byte(nil), mock.Anything).Return(true, nil).Once()
+	mockAPI.On("UnregisterCommand", "", "hello").Return(nil).Once()
+	mockAPI.On("KVGet", "bindings_inv").Return(nil, nil).Once()
+	mockAPI.On("KVSetWithOptions", "bindings_inv", mock.Anything, mock.Anything).Return(true, nil).Once()
+	mockAPI.On("PublishWebSocketEvent", WebSocketEventRefreshBindings, mock.Anything, &model.WebsocketBroadcast{}).Once()
+
+	err := s.UninstallApp("app")
+	require.NoError(t, err)
+	_, err = s.GetApp("app")
+	require.Equal(t, utils.ErrNotFound, err)
+	_, ok := s.commands.appID("hello")
+	require.False(t, ok)
+
+	err = s.UninstallApp("app")
+	require.Equal(t, utils.ErrNotFound, err)
+}
@@ -13,6 +13,9 @@ const (
 	PermissionAddGrants                     = PermissionType("add_grants")
 	PermissionActAsUser                     = PermissionType("act_as_user")
 	PermissionActAsBot                      = PermissionType("act_as_bot")
+	PermissionUserNotification              = PermissionType("user_notification")
+	PermissionTeamWideNotification          = PermissionType("team_wide_notification")
+	PermissionServerWideNotification        = PermissionType("server_wide_notification")
 )
 
 func (p Permissions) Contains(permission PermissionType) bool {
@@ -35,6 +38,12 @@ func (p PermissionType) Markdown() md.MD {
 		m = "Use Mattermost REST API as connected users"
 	case PermissionActAsBot:
 		m = "Use Mattermost REST API as the app's bot user"
+	case PermissionUserNotification:
+		m = "Be notified when users are created, and see their profiles"
+	case PermissionTeamWideNotification:
+		m = "Be notified about posts and channel membership in all public channels of a team"
+	case PermissionServerWideNotification:
+		m = "Be notified about posts and channel membership in all public channels"
 	default:
 		m = "unknown permission: " + string(p)
 	}
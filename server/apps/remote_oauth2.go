@@ -0,0 +1,62 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package apps
+
+import (
+	"golang.org/x/oauth2"
+)
+
+// RemoteOAuth2Provider is a third-party OAuth2 provider (e.g. GitHub, Jira)
+// declared in the App's manifest. The proxy runs the connect flow, and stores
+// and refreshes the users' tokens on the App's behalf.
+type RemoteOAuth2Provider struct {
+	ID          string   `json:"id"`
+	DisplayName string   `json:"display_name,omitempty"`
+	AuthURL     string   `json:"auth_url"`
+	TokenURL    string   `json:"token_url"`
+	Scopes      []string `json:"scopes,omitempty"`
+}
+
+// RemoteOAuth2Client contains the OAuth2 client credentials for a provider,
+// configured by a system administrator.
+type RemoteOAuth2Client struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+// RemoteOAuth2Token is the expanded value for a provider: the acting user's
+// access token if the user is connected, or the URL to connect at otherwise.
+type RemoteOAuth2Token struct {
+	AccessToken string `json:"access_token,omitempty"`
+	ConnectURL  string `json:"connect_url,omitempty"`
+}
+
+// RemoteOAuth2State is stored for the duration of a connect flow.
+type RemoteOAuth2State struct {
+	AppID      AppID  `json:"app_id"`
+	ProviderID string `json:"provider_id"`
+	UserID     string `json:"user_id"`
+}
+
+func (m *Manifest) GetRemoteOAuth2Provider(providerID string) *RemoteOAuth2Provider {
+	for _, p := range m.RemoteOAuth2Providers {
+		if p.ID == providerID {
+			return p
+		}
+	}
+	return nil
+}
+
+func (p *RemoteOAuth2Provider) OAuth2Config(client *RemoteOAuth2Client, redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     client.ClientID,
+		ClientSecret: client.ClientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  p.AuthURL,
+			TokenURL: p.TokenURL,
+		},
+		RedirectURL: redirectURL,
+		Scopes:      p.Scopes,
+	}
+}
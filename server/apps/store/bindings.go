@@ -0,0 +1,39 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package store
+
+import (
+	"encoding/json"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+)
+
+func (s *store) GetBindingsInvalidations() (map[apps.AppID]int64, error) {
+	invalidations := map[apps.AppID]int64{}
+	err := s.Mattermost.KV.Get(keyBindingsInvalidations, &invalidations)
+	if err != nil {
+		return nil, err
+	}
+	return invalidations, nil
+}
+
+func (s *store) InvalidateBindings(appID apps.AppID, at int64) error {
+	err := s.updateAtomic(keyBindingsInvalidations, "bindings invalidations", func(data []byte) (interface{}, error) {
+		invalidations := map[apps.AppID]int64{}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &invalidations); err != nil {
+				return nil, err
+			}
+		}
+		if invalidations[appID] >= at {
+			return nil, errNoChange
+		}
+		invalidations[appID] = at
+		return invalidations, nil
+	})
+	if err != nil && err != errNoChange {
+		return err
+	}
+	return nil
+}
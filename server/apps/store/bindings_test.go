@@ -0,0 +1,26 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+)
+
+func TestInvalidateBindings(t *testing.T) {
+	s, _ := newMemKVStore()
+
+	invalidations, err := s.GetBindingsInvalidations()
+	require.NoError(t, err)
+	require.Empty(t, invalidations)
+
+	require.NoError(t, s.InvalidateBindings("app1", 1000))
+	require.NoError(t, s.InvalidateBindings("app2", 2000))
+	// An earlier time does not undo a later invalidation.
+	require.NoError(t, s.InvalidateBindings("app1", 500))
+
+	invalidations, err = s.GetBindingsInvalidations()
+	require.NoError(t, err)
+	require.Equal(t, map[apps.AppID]int64{"app1": 1000, "app2": 2000}, invalidations)
+}
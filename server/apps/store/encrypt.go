@@ -0,0 +1,74 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/utils"
+)
+
+func (s *store) newAEAD() (cipher.AEAD, error) {
+	conf := s.Configurator.GetConfig()
+	if conf.StoredConfig == nil || conf.EncryptionKey == "" {
+		return nil, errors.New("encryption key is not configured")
+	}
+	key := sha256.Sum256([]byte(conf.EncryptionKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// setEncrypted stores the JSON of v, encrypted with the configured key.
+func (s *store) setEncrypted(key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	aead, err := s.newAEAD()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	_, err = s.Mattermost.KV.Set(key, aead.Seal(nonce, nonce, data, []byte(key)))
+	return err
+}
+
+// getEncrypted loads and decrypts the value stored with setEncrypted, or
+// returns utils.ErrNotFound.
+func (s *store) getEncrypted(key string, out interface{}) error {
+	var sealed []byte
+	err := s.Mattermost.KV.Get(key, &sealed)
+	if err != nil {
+		return err
+	}
+	if len(sealed) == 0 {
+		return utils.ErrNotFound
+	}
+	aead, err := s.newAEAD()
+	if err != nil {
+		return err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return errors.New("failed to decrypt: invalid data")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	data, err := aead.Open(nil, nonce, ciphertext, []byte(key))
+	if err != nil {
+		return errors.Wrap(err, "failed to decrypt")
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package store
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	pluginapi "github.com/mattermost/mattermost-plugin-api"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+)
+
+// callFormKey identifies the form of a Call for a user, hashed to stay within
+// the KV key length limit.
+func callFormKey(appID apps.AppID, userID, url string) string {
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%s", appID, userID, url)))
+	return fmt.Sprintf("%s%x", prefixCallForm, h[:16])
+}
+
+// CallForm is a form given to a user, with the values it was made for, to
+// tell when a field it DependsOn has changed.
+type CallForm struct {
+	Form   *apps.Form      `json:"form"`
+	Values apps.CallValues `json:"values,omitempty"`
+
+	// Embedded is set for the forms embedded in the bindings, they are not
+	// stored.
+	Embedded bool `json:"-"`
+}
+
+func (s *store) StoreCallForm(appID apps.AppID, userID, url string, form *CallForm, ttl time.Duration) error {
+	_, err := s.Mattermost.KV.Set(callFormKey(appID, userID, url), form, pluginapi.SetExpiry(ttl))
+	return err
+}
+
+// GetCallForm returns nil if there is no form stored for the Call.
+func (s *store) GetCallForm(appID apps.AppID, userID, url string) (*CallForm, error) {
+	var form *CallForm
+	err := s.Mattermost.KV.Get(callFormKey(appID, userID, url), &form)
+	if err != nil {
+		return nil, err
+	}
+	return form, nil
+}
+
+func (s *store) DeleteCallForm(appID apps.AppID, userID, url string) error {
+	return s.Mattermost.KV.Delete(callFormKey(appID, userID, url))
+}
@@ -0,0 +1,44 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+)
+
+func TestCallForms(t *testing.T) {
+	s, _ := newMemKVStore()
+	form := &CallForm{
+		Form: &apps.Form{
+			Title:     "title",
+			DependsOn: []string{"type"},
+			Fields:    []*apps.Field{{Name: "message", Type: apps.FieldTypeText, IsRequired: true}},
+		},
+		Values: apps.CallValues{"type": "dm"},
+	}
+
+	stored, err := s.GetCallForm("app", "user1", "/send")
+	require.NoError(t, err)
+	require.Nil(t, stored)
+
+	require.NoError(t, s.StoreCallForm("app", "user1", "/send", form, time.Minute))
+	stored, err = s.GetCallForm("app", "user1", "/send")
+	require.NoError(t, err)
+	require.Equal(t, form, stored)
+
+	// The forms are by user and URL.
+	stored, err = s.GetCallForm("app", "user2", "/send")
+	require.NoError(t, err)
+	require.Nil(t, stored)
+	stored, err = s.GetCallForm("app", "user1", "/other")
+	require.NoError(t, err)
+	require.Nil(t, stored)
+
+	require.NoError(t, s.DeleteCallForm("app", "user1", "/send"))
+	stored, err = s.GetCallForm("app", "user1", "/send")
+	require.NoError(t, err)
+	require.Nil(t, stored)
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"bytes"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+)
+
+// memKV is an in-memory KV store with the plugin API's atomic set semantics.
+// The other plugin API methods are mocked.
+type memKV struct {
+	plugintest.API
+
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func newMemKV() *memKV {
+	return &memKV{
+		data: map[string][]byte{},
+	}
+}
+
+func (kv *memKV) KVGet(key string) ([]byte, *model.AppError) {
+	kv.mu.Lock()
+	value := kv.data[key]
+	kv.mu.Unlock()
+
+	// Give the other goroutines a chance to modify the value before it is set.
+	runtime.Gosched()
+	return value, nil
+}
+
+func (kv *memKV) KVSetWithOptions(key string, value []byte, options model.PluginKVSetOptions) (bool, *model.AppError) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	if options.Atomic && !bytes.Equal(kv.data[key], options.OldValue) {
+		return false, nil
+	}
+	if value == nil {
+		delete(kv.data, key)
+		return true, nil
+	}
+	kv.data[key] = append([]byte{}, value...)
+	return true, nil
+}
+
+func (kv *memKV) KVList(page, perPage int) ([]string, *model.AppError) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	keys := []string{}
+	for key := range kv.data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	start := page * perPage
+	if start >= len(keys) {
+		return []string{}, nil
+	}
+	end := start + perPage
+	if end > len(keys) {
+		end = len(keys)
+	}
+	return keys[start:end], nil
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package store
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	pluginapi "github.com/mattermost/mattermost-plugin-api"
+	"github.com/mattermost/mattermost-server/v5/model"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+	"github.com/mattermost/mattermost-plugin-apps/server/utils"
+)
+
+const remoteOAuth2StateTTL = 10 * time.Minute
+
+// remoteOAuth2Hash identifies an App's provider within the KV key length
+// limit.
+func remoteOAuth2Hash(appID apps.AppID, providerID string) string {
+	h := sha256.Sum256([]byte(string(appID) + "/" + providerID))
+	return fmt.Sprintf("%x", h[:6])
+}
+
+func remoteOAuth2ClientKey(appID apps.AppID, providerID string) string {
+	return prefixRemoteOAuth2Client + remoteOAuth2Hash(appID, providerID)
+}
+
+func remoteOAuth2TokenKey(appID apps.AppID, providerID, userID string) string {
+	return prefixRemoteOAuth2Token + remoteOAuth2Hash(appID, providerID) + "_" + userID
+}
+
+func (s *store) GetRemoteOAuth2Client(appID apps.AppID, providerID string) (*apps.RemoteOAuth2Client, error) {
+	var client apps.RemoteOAuth2Client
+	err := s.getEncrypted(remoteOAuth2ClientKey(appID, providerID), &client)
+	if err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+func (s *store) StoreRemoteOAuth2Client(appID apps.AppID, providerID string, client *apps.RemoteOAuth2Client) error {
+	return s.setEncrypted(remoteOAuth2ClientKey(appID, providerID), client)
+}
+
+func (s *store) GetRemoteOAuth2Token(appID apps.AppID, providerID, userID string) (*oauth2.Token, error) {
+	var token oauth2.Token
+	err := s.getEncrypted(remoteOAuth2TokenKey(appID, providerID, userID), &token)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (s *store) StoreRemoteOAuth2Token(appID apps.AppID, providerID, userID string, token *oauth2.Token) error {
+	return s.setEncrypted(remoteOAuth2TokenKey(appID, providerID, userID), token)
+}
+
+func (s *store) CreateRemoteOAuth2State(state *apps.RemoteOAuth2State) (string, error) {
+	id := model.NewId()
+	_, err := s.Mattermost.KV.Set(prefixRemoteOAuth2State+id, state, pluginapi.SetExpiry(remoteOAuth2StateTTL))
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// ConsumeRemoteOAuth2State returns the state, and deletes it so that it can
+// only be used once.
+func (s *store) ConsumeRemoteOAuth2State(id string) (*apps.RemoteOAuth2State, error) {
+	key := prefixRemoteOAuth2State + id
+	var state *apps.RemoteOAuth2State
+	err := s.Mattermost.KV.Get(key, &state)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return nil, utils.ErrNotFound
+	}
+	err = s.Mattermost.KV.Delete(key)
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"bytes"
+	"testing"
+
+	pluginapi "github.com/mattermost/mattermost-plugin-api"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+	"github.com/mattermost/mattermost-plugin-apps/server/configurator"
+	"github.com/mattermost/mattermost-plugin-apps/server/utils"
+)
+
+func newTestStore(mockAPI *plugintest.API, encryptionKey string) *store {
+	conf := configurator.NewTestConfigurator(&configurator.Config{
+		StoredConfig: &configurator.StoredConfig{
+			EncryptionKey: encryptionKey,
+		},
+	})
+	return NewService(pluginapi.NewClient(mockAPI), conf).(*store)
+}
+
+func TestRemoteOAuth2TokenEncrypted(t *testing.T) {
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+
+	key := remoteOAuth2TokenKey("app-id", "github", "user-id")
+	var stored []byte
+	mockAPI.On("KVSetWithOptions", key, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			stored = args.Get(1).([]byte)
+		}).
+		Return(true, nil)
+	mockAPI.On("KVGet", key).Return(func(string) []byte { return stored }, nil)
+
+	s := newTestStore(mockAPI, "encryption-key")
+	err := s.StoreRemoteOAuth2Token("app-id", "github", "user-id", &oauth2.Token{
+		AccessToken:  "access-token",
+		RefreshToken: "refresh-token",
+	})
+	require.NoError(t, err)
+	require.False(t, bytes.Contains(stored, []byte("access-token")))
+	require.False(t, bytes.Contains(stored, []byte("refresh-token")))
+
+	token, err := s.GetRemoteOAuth2Token("app-id", "github", "user-id")
+	require.NoError(t, err)
+	require.Equal(t, "access-token", token.AccessToken)
+	require.Equal(t, "refresh-token", token.RefreshToken)
+
+	// A different key can not decrypt the stored token.
+	other := newTestStore(mockAPI, "another-key")
+	_, err = other.GetRemoteOAuth2Token("app-id", "github", "user-id")
+	require.Error(t, err)
+}
+
+func TestRemoteOAuth2Errors(t *testing.T) {
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+
+	s := newTestStore(mockAPI, "")
+	err := s.StoreRemoteOAuth2Client("app-id", "github", &apps.RemoteOAuth2Client{ClientID: "id", ClientSecret: "secret"})
+	require.EqualError(t, err, "encryption key is not configured")
+
+	mockAPI.On("KVGet", remoteOAuth2ClientKey("app-id", "github")).Return(nil, nil)
+	_, err = newTestStore(mockAPI, "encryption-key").GetRemoteOAuth2Client("app-id", "github")
+	require.Equal(t, utils.ErrNotFound, err)
+}
+
+func TestRemoteOAuth2Keys(t *testing.T) {
+	key := remoteOAuth2TokenKey("some-rather-long-app-id-that-would-not-fit", "some-provider-id", model.NewId())
+	require.LessOrEqual(t, len(key), 50)
+	require.NotEqual(t,
+		remoteOAuth2TokenKey("app-id", "github", "user-id"),
+		remoteOAuth2TokenKey("app-id", "jira", "user-id"))
+}
@@ -4,17 +4,69 @@
 package store
 
 import (
+	"time"
+
+	"golang.org/x/oauth2"
+
 	pluginapi "github.com/mattermost/mattermost-plugin-api"
 	"github.com/mattermost/mattermost-plugin-apps/server/apps"
 	"github.com/mattermost/mattermost-plugin-apps/server/configurator"
 )
 
-const prefixSubs = "sub_"
+const (
+	keyBindingsInvalidations = "bindings_inv"
+	keyAppSubsIndexVersion   = "suba_version"
+
+	prefixSubs               = "sub_"
+	prefixAppSubsIndex       = "suba_"
+	prefixTeamSubsFlag       = "subt_"
+	prefixSubFailingSince    = "subf_"
+	prefixSubExpiring        = "subx_"
+	prefixCallForm           = "form_"
+	prefixRemoteOAuth2Client = "ro2c_"
+	prefixRemoteOAuth2Token  = "ro2t_"
+	prefixRemoteOAuth2State  = "ro2s_"
+)
 
 type Service interface {
 	DeleteSub(*apps.Subscription) error
 	GetSubs(subject apps.Subject, teamID, channelID string) ([]*apps.Subscription, error)
+	FindSubs(subject apps.Subject, teamID, channelID string) ([]*apps.Subscription, error)
+	HasTeamSubs(subject apps.Subject) (bool, error)
 	StoreSub(sub *apps.Subscription) error
+	ListSubs(appID apps.AppID) ([]*apps.Subscription, error)
+	DeleteAllSubs(appID apps.AppID) error
+	MigrateAppSubsIndex() error
+	SuspendSub(sub *apps.Subscription, at int64) error
+
+	// Deliveries that keep failing get a subscription suspended, the failure
+	// streak is reset on a successful delivery.
+	GetSubFailingSince(*apps.Subscription) (int64, error)
+	RecordSubDeliveryFailure(sub *apps.Subscription, at int64) error
+	ResetSubDeliveryFailures(*apps.Subscription) error
+
+	// MarkSubExpiring returns true the first time it is called for the
+	// subscription's current expiry.
+	MarkSubExpiring(sub *apps.Subscription, ttl time.Duration) (bool, error)
+
+	// The bindings are cached in memory on each node, the invalidations are
+	// shared as the last invalidation time by App.
+	GetBindingsInvalidations() (map[apps.AppID]int64, error)
+	InvalidateBindings(appID apps.AppID, at int64) error
+
+	// The forms that the Apps return to the users are kept for a while, to
+	// validate the values submitted with them.
+	StoreCallForm(appID apps.AppID, userID, url string, form *CallForm, ttl time.Duration) error
+	GetCallForm(appID apps.AppID, userID, url string) (*CallForm, error)
+	DeleteCallForm(appID apps.AppID, userID, url string) error
+
+	// Third-party OAuth2 client credentials and tokens are stored encrypted.
+	GetRemoteOAuth2Client(appID apps.AppID, providerID string) (*apps.RemoteOAuth2Client, error)
+	StoreRemoteOAuth2Client(appID apps.AppID, providerID string, client *apps.RemoteOAuth2Client) error
+	GetRemoteOAuth2Token(appID apps.AppID, providerID, userID string) (*oauth2.Token, error)
+	StoreRemoteOAuth2Token(appID apps.AppID, providerID, userID string, token *oauth2.Token) error
+	CreateRemoteOAuth2State(*apps.RemoteOAuth2State) (string, error)
+	ConsumeRemoteOAuth2State(id string) (*apps.RemoteOAuth2State, error)
 }
 
 type store struct {
@@ -0,0 +1,90 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package store
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	pluginapi "github.com/mattermost/mattermost-plugin-api"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+	"github.com/mattermost/mattermost-plugin-apps/server/utils"
+)
+
+// subStatusKey identifies a subscription by its App and scope, hashed to stay
+// within the KV key length limit.
+func subStatusKey(prefix string, sub *apps.Subscription, extra ...interface{}) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%s|%s|%s", sub.AppID, sub.Subject, sub.TeamID, sub.ChannelID)
+	for _, e := range extra {
+		fmt.Fprintf(h, "|%v", e)
+	}
+	return fmt.Sprintf("%s%x", prefix, h.Sum(nil)[:16])
+}
+
+// SuspendSub marks the stored subscription with the same scope as suspended.
+func (s *store) SuspendSub(sub *apps.Subscription, at int64) error {
+	key := s.subsKey(sub.Subject, sub.TeamID, sub.ChannelID)
+	err := s.updateSubsAtomic(key, func(subs []*apps.Subscription) ([]*apps.Subscription, error) {
+		for i, current := range subs {
+			if !current.EqualScope(sub) {
+				continue
+			}
+			if current.SuspendedAt != 0 {
+				return nil, errNoChange
+			}
+			suspended := *current
+			suspended.SuspendedAt = at
+			updated := append([]*apps.Subscription{}, subs...)
+			updated[i] = &suspended
+			return updated, nil
+		}
+		return nil, utils.ErrNotFound
+	})
+	if err != nil && err != errNoChange {
+		return err
+	}
+	return nil
+}
+
+// GetSubFailingSince returns when the deliveries of the subscription started
+// failing, or 0.
+func (s *store) GetSubFailingSince(sub *apps.Subscription) (int64, error) {
+	var since int64
+	err := s.Mattermost.KV.Get(subStatusKey(prefixSubFailingSince, sub), &since)
+	if err != nil {
+		return 0, err
+	}
+	return since, nil
+}
+
+// RecordSubDeliveryFailure starts the subscription's failure streak at at,
+// unless one is already in progress.
+func (s *store) RecordSubDeliveryFailure(sub *apps.Subscription, at int64) error {
+	_, err := s.Mattermost.KV.Set(subStatusKey(prefixSubFailingSince, sub), at, pluginapi.SetAtomic(nil))
+	return err
+}
+
+// ResetSubDeliveryFailures ends the subscription's failure streak. It is
+// called on every successful delivery, so the failure record is read first,
+// and deleted only if there is one.
+func (s *store) ResetSubDeliveryFailures(sub *apps.Subscription) error {
+	since, err := s.GetSubFailingSince(sub)
+	if err != nil {
+		return err
+	}
+	if since == 0 {
+		return nil
+	}
+	return s.Mattermost.KV.Delete(subStatusKey(prefixSubFailingSince, sub))
+}
+
+// MarkSubExpiring uses a marker that expires after ttl, keyed by the
+// subscription's ExpiresAt, so that a renewed subscription is reported again.
+func (s *store) MarkSubExpiring(sub *apps.Subscription, ttl time.Duration) (bool, error) {
+	return s.Mattermost.KV.Set(subStatusKey(prefixSubExpiring, sub, sub.ExpiresAt), true,
+		pluginapi.SetAtomic(nil), pluginapi.SetExpiry(ttl))
+}
@@ -0,0 +1,97 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+)
+
+func TestSuspendSub(t *testing.T) {
+	s, _ := newMemKVStore()
+	sub := newChannelSub(1)
+	require.NoError(t, s.StoreSub(sub))
+	require.NoError(t, s.StoreSub(newChannelSub(2)))
+
+	require.NoError(t, s.SuspendSub(sub, 1234))
+	// Suspending again keeps the original time.
+	require.NoError(t, s.SuspendSub(sub, 5678))
+	subs, err := s.GetSubs(sub.Subject, "", sub.ChannelID)
+	require.NoError(t, err)
+	require.Len(t, subs, 2)
+	require.EqualValues(t, 1234, subs[0].SuspendedAt)
+	require.EqualValues(t, 0, subs[1].SuspendedAt)
+
+	// Storing the subscription again replaces the suspended one.
+	require.NoError(t, s.StoreSub(sub))
+	subs, err = s.GetSubs(sub.Subject, "", sub.ChannelID)
+	require.NoError(t, err)
+	require.EqualValues(t, 0, subs[0].SuspendedAt)
+
+	require.Error(t, s.SuspendSub(newChannelSub(3), 1234))
+}
+
+func TestSubDeliveryFailures(t *testing.T) {
+	s, _ := newMemKVStore()
+	sub := newChannelSub(1)
+
+	since, err := s.GetSubFailingSince(sub)
+	require.NoError(t, err)
+	require.EqualValues(t, 0, since)
+
+	require.NoError(t, s.RecordSubDeliveryFailure(sub, 1000))
+	require.NoError(t, s.RecordSubDeliveryFailure(sub, 2000))
+	since, err = s.GetSubFailingSince(sub)
+	require.NoError(t, err)
+	require.EqualValues(t, 1000, since)
+
+	// Other subscriptions are tracked separately.
+	since, err = s.GetSubFailingSince(newChannelSub(2))
+	require.NoError(t, err)
+	require.EqualValues(t, 0, since)
+
+	require.NoError(t, s.ResetSubDeliveryFailures(sub))
+	since, err = s.GetSubFailingSince(sub)
+	require.NoError(t, err)
+	require.EqualValues(t, 0, since)
+}
+
+func TestMarkSubExpiring(t *testing.T) {
+	s, _ := newMemKVStore()
+	sub := newChannelSub(1)
+	sub.ExpiresAt = 1000
+
+	first, err := s.MarkSubExpiring(sub, time.Hour)
+	require.NoError(t, err)
+	require.True(t, first)
+	first, err = s.MarkSubExpiring(sub, time.Hour)
+	require.NoError(t, err)
+	require.False(t, first)
+
+	// Renewed.
+	renewed := *sub
+	renewed.ExpiresAt = 2000
+	first, err = s.MarkSubExpiring(&renewed, time.Hour)
+	require.NoError(t, err)
+	require.True(t, first)
+
+	other := &apps.Subscription{AppID: "other-app-id", Subject: sub.Subject, ChannelID: sub.ChannelID, ExpiresAt: 1000}
+	first, err = s.MarkSubExpiring(other, time.Hour)
+	require.NoError(t, err)
+	require.True(t, first)
+}
+
+func TestResetSubDeliveryFailuresNoWrite(t *testing.T) {
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	s := newTestStore(mockAPI, "")
+
+	// A subscription that is not failing is reset without writing to the KV
+	// store, the mock fails on any KVSetWithOptions.
+	mockAPI.On("KVGet", mock.AnythingOfType("string")).Return(nil, nil).Once()
+	require.NoError(t, s.ResetSubDeliveryFailures(newChannelSub(1)))
+}
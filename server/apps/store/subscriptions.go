@@ -4,21 +4,59 @@
 package store
 
 import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	pluginapi "github.com/mattermost/mattermost-plugin-api"
+
 	"github.com/mattermost/mattermost-plugin-apps/server/apps"
 	"github.com/mattermost/mattermost-plugin-apps/server/utils"
 	"github.com/pkg/errors"
 )
 
+const (
+	atomicUpdateRetries      = 50
+	atomicUpdateMaxBackoffMS = 10
+
+	// appSubsIndexVersion is the version of the per-App index of the
+	// subscription keys, see MigrateAppSubsIndex.
+	appSubsIndexVersion = 2
+	listKeysPerPage     = 1000
+)
+
+// errNoChange is returned by the atomic update functions to skip the update.
+var errNoChange = errors.New("no change")
+
 func (s *store) subsKey(subject apps.Subject, teamID, channelID string) string {
 	idSuffix := ""
 	switch subject {
+	case apps.SubjectUserCreated,
+		apps.SubjectUserUpdated,
+		apps.SubjectTeamCreated:
+		// global
 	case apps.SubjectUserJoinedChannel,
 		apps.SubjectUserLeftChannel,
-		apps.SubjectPostCreated:
-		idSuffix = "_" + channelID
+		apps.SubjectPostCreated,
+		apps.SubjectPostUpdated:
+		switch {
+		case channelID != "":
+			idSuffix = "_" + channelID
+		case teamID != "":
+			idSuffix = "_t_" + teamID
+		default:
+			// server-wide
+		}
 	case apps.SubjectUserJoinedTeam,
 		apps.SubjectUserLeftTeam,
-		apps.SubjectChannelCreated:
+		apps.SubjectChannelCreated,
+		apps.SubjectChannelArchived,
+		apps.SubjectChannelRestored,
+		apps.SubjectChannelUpdated,
+		apps.SubjectTeamUpdated:
 		idSuffix = "_" + teamID
 	}
 	return prefixSubs + string(subject) + idSuffix
@@ -26,32 +64,37 @@ func (s *store) subsKey(subject apps.Subject, teamID, channelID string) string {
 
 func (s *store) DeleteSub(sub *apps.Subscription) error {
 	key := s.subsKey(sub.Subject, sub.TeamID, sub.ChannelID)
-	// get all subscriptions for the subject
-	var subs []*apps.Subscription
-	err := s.Mattermost.KV.Get(key, &subs)
-	if err != nil {
-		return err
-	}
-
-	for i, current := range subs {
-		if !sub.EqualScope(current) {
-			continue
-		}
+	appHasOtherSubs := false
+	err := s.updateSubsAtomic(key, func(subs []*apps.Subscription) ([]*apps.Subscription, error) {
+		for i, current := range subs {
+			if !sub.EqualScope(current) {
+				continue
+			}
 
-		// sub exists and needs to be deleted
-		updated := subs[:i]
-		if i < len(subs) {
+			// sub exists and needs to be deleted
+			updated := append([]*apps.Subscription{}, subs[:i]...)
 			updated = append(updated, subs[i+1:]...)
+			appHasOtherSubs = false
+			for _, other := range updated {
+				if other.AppID == sub.AppID {
+					appHasOtherSubs = true
+					break
+				}
+			}
+			return updated, nil
 		}
+		return nil, utils.ErrNotFound
+	})
+	if err != nil {
+		return err
+	}
 
-		_, err = s.Mattermost.KV.Set(key, updated)
-		if err != nil {
-			return errors.Wrap(err, "failed to save subscriptions")
-		}
+	// The App may have other subscriptions with the same key, e.g. with a
+	// different scope.
+	if appHasOtherSubs {
 		return nil
 	}
-
-	return utils.ErrNotFound
+	return s.updateAppSubsIndex(sub.AppID, key, false)
 }
 
 func (s *store) GetSubs(subject apps.Subject, teamID, channelID string) ([]*apps.Subscription, error) {
@@ -67,30 +110,283 @@ func (s *store) GetSubs(subject apps.Subject, teamID, channelID string) ([]*apps
 	return subs, nil
 }
 
+// FindSubs returns the subscriptions that match an event. For the
+// channel-scoped subjects it merges the channel, team-wide and server-wide
+// subscriptions.
+func (s *store) FindSubs(subject apps.Subject, teamID, channelID string) ([]*apps.Subscription, error) {
+	if !subject.IsChannelScoped() {
+		return s.GetSubs(subject, teamID, channelID)
+	}
+
+	keys := []string{}
+	if channelID != "" {
+		keys = append(keys, s.subsKey(subject, "", channelID))
+	}
+	if teamID != "" {
+		keys = append(keys, s.subsKey(subject, teamID, ""))
+	}
+	keys = append(keys, s.subsKey(subject, "", ""))
+
+	var out []*apps.Subscription
+	for _, key := range keys {
+		var subs []*apps.Subscription
+		err := s.Mattermost.KV.Get(key, &subs)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, subs...)
+	}
+	if len(out) == 0 {
+		return nil, utils.ErrNotFound
+	}
+	return out, nil
+}
+
+// HasTeamSubs returns true if team-wide subscriptions to the subject may
+// exist, so that the team of a channel only needs to be looked up for them.
+// The flag is not cleared when the subscriptions are deleted, it only costs a
+// lookup.
+func (s *store) HasTeamSubs(subject apps.Subject) (bool, error) {
+	var has bool
+	err := s.Mattermost.KV.Get(prefixTeamSubsFlag+string(subject), &has)
+	if err != nil {
+		return false, err
+	}
+	return has, nil
+}
+
+func (s *store) flagTeamSubs(sub *apps.Subscription) error {
+	if !sub.IsWide() || sub.TeamID == "" {
+		return nil
+	}
+	_, err := s.Mattermost.KV.Set(prefixTeamSubsFlag+string(sub.Subject), true)
+	return err
+}
+
 func (s *store) StoreSub(sub *apps.Subscription) error {
 	key := s.subsKey(sub.Subject, sub.TeamID, sub.ChannelID)
-	// get all subscriptions for the subject
-	var subs []*apps.Subscription
-	err := s.Mattermost.KV.Get(key, &subs)
+	err := s.flagTeamSubs(sub)
 	if err != nil {
 		return err
 	}
+	err = s.updateSubsAtomic(key, func(subs []*apps.Subscription) ([]*apps.Subscription, error) {
+		updated := append([]*apps.Subscription{}, subs...)
+		for i, current := range updated {
+			if current.EqualScope(sub) {
+				updated[i] = sub
+				return updated, nil
+			}
+		}
+		return append(updated, sub), nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.updateAppSubsIndex(sub.AppID, key, true)
+}
 
-	add := true
-	for i, s := range subs {
-		if s.EqualScope(sub) {
-			subs[i] = sub
-			add = false
-			break
+// ListSubs returns all subscriptions of the App, using the per-App index of
+// the subscription keys.
+func (s *store) ListSubs(appID apps.AppID) ([]*apps.Subscription, error) {
+	keys, err := s.getAppSubsIndex(appID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := []*apps.Subscription{}
+	for _, key := range keys {
+		var subs []*apps.Subscription
+		err = s.Mattermost.KV.Get(key, &subs)
+		if err != nil {
+			return nil, err
+		}
+		for _, sub := range subs {
+			if sub.AppID == appID {
+				out = append(out, sub)
+			}
 		}
 	}
-	if add {
-		subs = append(subs, sub)
+	return out, nil
+}
+
+// DeleteAllSubs deletes all subscriptions of the App, and its index.
+func (s *store) DeleteAllSubs(appID apps.AppID) error {
+	keys, err := s.getAppSubsIndex(appID)
+	if err != nil {
+		return err
 	}
 
-	_, err = s.Mattermost.KV.Set(key, subs)
+	for _, key := range keys {
+		err = s.updateSubsAtomic(key, func(subs []*apps.Subscription) ([]*apps.Subscription, error) {
+			updated := []*apps.Subscription{}
+			for _, sub := range subs {
+				if sub.AppID != appID {
+					updated = append(updated, sub)
+				}
+			}
+			if len(updated) == len(subs) {
+				return nil, errNoChange
+			}
+			return updated, nil
+		})
+		if err != nil && err != errNoChange {
+			return err
+		}
+	}
+
+	return s.Mattermost.KV.Delete(appSubsIndexKey(appID))
+}
+
+// MigrateAppSubsIndex adds the subscriptions that were stored before there
+// was a per-App index to it, by scanning all the subscription keys. The
+// team-wide subscriptions are flagged too, see HasTeamSubs. It only runs once,
+// the index version is stored when it is done. The index updates
+// are idempotent, so it is safe to run concurrently on several nodes.
+func (s *store) MigrateAppSubsIndex() error {
+	var version int
+	err := s.Mattermost.KV.Get(keyAppSubsIndexVersion, &version)
 	if err != nil {
 		return err
 	}
+	if version >= appSubsIndexVersion {
+		return nil
+	}
+
+	subsKeys := []string{}
+	for page := 0; ; page++ {
+		keys, err := s.Mattermost.KV.ListKeys(page, listKeysPerPage)
+		if err != nil {
+			return errors.Wrap(err, "failed to list the keys")
+		}
+		for _, key := range keys {
+			if strings.HasPrefix(key, prefixSubs) {
+				subsKeys = append(subsKeys, key)
+			}
+		}
+		if len(keys) < listKeysPerPage {
+			break
+		}
+	}
+
+	for _, key := range subsKeys {
+		var subs []*apps.Subscription
+		err = s.Mattermost.KV.Get(key, &subs)
+		if err != nil {
+			return err
+		}
+		for _, sub := range subs {
+			err = s.updateAppSubsIndex(sub.AppID, key, true)
+			if err != nil {
+				return err
+			}
+			err = s.flagTeamSubs(sub)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = s.Mattermost.KV.Set(keyAppSubsIndexVersion, appSubsIndexVersion)
+	return err
+}
+
+// appSubsIndexKey uses a hash of the App ID to stay within the KV key length
+// limit.
+func appSubsIndexKey(appID apps.AppID) string {
+	h := sha256.Sum256([]byte(appID))
+	return fmt.Sprintf("%s%x", prefixAppSubsIndex, h[:8])
+}
+
+func (s *store) getAppSubsIndex(appID apps.AppID) ([]string, error) {
+	var keys []string
+	err := s.Mattermost.KV.Get(appSubsIndexKey(appID), &keys)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// updateAppSubsIndex adds, or removes the subscription key in the App's
+// index.
+func (s *store) updateAppSubsIndex(appID apps.AppID, subsKey string, add bool) error {
+	err := s.updateAtomic(appSubsIndexKey(appID), "index", func(data []byte) (interface{}, error) {
+		var keys []string
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &keys); err != nil {
+				return nil, err
+			}
+		}
+
+		updated := []string{}
+		found := false
+		for _, key := range keys {
+			if key == subsKey {
+				found = true
+				if !add {
+					continue
+				}
+			}
+			updated = append(updated, key)
+		}
+		if found == add {
+			return nil, errNoChange
+		}
+		if add {
+			updated = append(updated, subsKey)
+		}
+		if len(updated) == 0 {
+			// deletes the index
+			return nil, nil
+		}
+		return updated, nil
+	})
+	if err != nil && err != errNoChange {
+		return errors.Wrap(err, "failed to update the app's subscriptions index")
+	}
 	return nil
 }
+
+// updateSubsAtomic applies update to the subscriptions stored in key. It
+// retries if the value was concurrently modified, e.g. on another node.
+func (s *store) updateSubsAtomic(key string, update func([]*apps.Subscription) ([]*apps.Subscription, error)) error {
+	return s.updateAtomic(key, "subscriptions", func(data []byte) (interface{}, error) {
+		var subs []*apps.Subscription
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &subs); err != nil {
+				return nil, err
+			}
+		}
+		return update(subs)
+	})
+}
+
+// updateAtomic is a compare-and-set loop: update computes the new value from
+// the current one, and the value is only set if it has not been changed in
+// the meantime. A nil new value deletes the key. Errors from update are
+// returned as they are.
+func (s *store) updateAtomic(key, name string, update func(data []byte) (interface{}, error)) error {
+	for i := 0; i < atomicUpdateRetries; i++ {
+		var data []byte
+		err := s.Mattermost.KV.Get(key, &data)
+		if err != nil {
+			return err
+		}
+
+		value, err := update(data)
+		if err != nil {
+			return err
+		}
+
+		saved, err := s.Mattermost.KV.Set(key, value, pluginapi.SetAtomic(data))
+		if err != nil {
+			return errors.Wrapf(err, "failed to save %s", name)
+		}
+		if saved {
+			return nil
+		}
+
+		// The value was changed concurrently, back off and retry.
+		time.Sleep(time.Duration(rand.Intn(atomicUpdateMaxBackoffMS)+1) * time.Millisecond)
+	}
+	return errors.Errorf("failed to update %s after %d retries", key, atomicUpdateRetries)
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	pluginapi "github.com/mattermost/mattermost-plugin-api"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+	"github.com/mattermost/mattermost-plugin-apps/server/configurator"
+)
+
+const concurrency = 50
+
+func newMemKVStore() (*store, *memKV) {
+	kv := newMemKV()
+	apiClient := pluginapi.NewClient(kv)
+	conf := configurator.NewTestConfigurator(&configurator.Config{})
+	return NewService(apiClient, conf).(*store), kv
+}
+
+func newChannelSub(i int) *apps.Subscription {
+	return &apps.Subscription{
+		Subject:   apps.SubjectPostCreated,
+		ChannelID: "channel-id",
+		AppID:     apps.AppID(fmt.Sprintf("app-%v", i)),
+	}
+}
+
+// runConcurrently runs f(i) for i in [0, n) in parallel, and waits for all.
+func runConcurrently(t *testing.T, n int, f func(i int) error) {
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- f(i)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+}
+
+func TestStoreSubConcurrent(t *testing.T) {
+	s, _ := newMemKVStore()
+
+	runConcurrently(t, concurrency, func(i int) error {
+		return s.StoreSub(newChannelSub(i))
+	})
+
+	subs, err := s.GetSubs(apps.SubjectPostCreated, "", "channel-id")
+	require.NoError(t, err)
+	require.Len(t, subs, concurrency)
+	for i := 0; i < concurrency; i++ {
+		appSubs, err := s.ListSubs(apps.AppID(fmt.Sprintf("app-%v", i)))
+		require.NoError(t, err)
+		require.Equal(t, []*apps.Subscription{newChannelSub(i)}, appSubs)
+	}
+}
+
+func TestStoreAndDeleteSubConcurrent(t *testing.T) {
+	s, _ := newMemKVStore()
+	for i := 0; i < concurrency; i++ {
+		require.NoError(t, s.StoreSub(newChannelSub(i)))
+	}
+
+	// Delete the first half, and add as many new ones.
+	runConcurrently(t, concurrency, func(i int) error {
+		if i < concurrency/2 {
+			return s.DeleteSub(newChannelSub(i))
+		}
+		return s.StoreSub(newChannelSub(i + concurrency))
+	})
+
+	subs, err := s.GetSubs(apps.SubjectPostCreated, "", "channel-id")
+	require.NoError(t, err)
+	var expected []*apps.Subscription
+	for i := concurrency / 2; i < concurrency; i++ {
+		expected = append(expected, newChannelSub(i), newChannelSub(i+concurrency))
+	}
+	require.ElementsMatch(t, expected, subs)
+}
+
+func TestAppSubsIndexConcurrent(t *testing.T) {
+	s, kv := newMemKVStore()
+
+	// One App subscribes to many channels at once.
+	runConcurrently(t, concurrency, func(i int) error {
+		return s.StoreSub(&apps.Subscription{
+			Subject:   apps.SubjectUserJoinedChannel,
+			ChannelID: fmt.Sprintf("channel-%v", i),
+			AppID:     "app-id",
+		})
+	})
+	subs, err := s.ListSubs("app-id")
+	require.NoError(t, err)
+	require.Len(t, subs, concurrency)
+
+	// Then unsubscribes from all of them, while another App subscribes.
+	runConcurrently(t, concurrency, func(i int) error {
+		if i%2 == 0 {
+			return s.DeleteSub(&apps.Subscription{
+				Subject:   apps.SubjectUserJoinedChannel,
+				ChannelID: fmt.Sprintf("channel-%v", i),
+				AppID:     "app-id",
+			})
+		}
+		return s.StoreSub(&apps.Subscription{
+			Subject:   apps.SubjectUserJoinedChannel,
+			ChannelID: fmt.Sprintf("channel-%v", i),
+			AppID:     "other-app-id",
+		})
+	})
+	subs, err = s.ListSubs("app-id")
+	require.NoError(t, err)
+	require.Len(t, subs, concurrency/2)
+	subs, err = s.ListSubs("other-app-id")
+	require.NoError(t, err)
+	require.Len(t, subs, concurrency/2)
+
+	require.NoError(t, s.DeleteAllSubs("app-id"))
+	require.NoError(t, s.DeleteAllSubs("other-app-id"))
+	for key, value := range kv.data {
+		require.Equal(t, "[]", string(value), key)
+	}
+}
@@ -76,6 +76,8 @@ func TestDeleteSub(t *testing.T) {
 	emptySubsBytes, _ := json.Marshal(emptySubs)
 
 	subKey := "sub_user_joined_channel_channel-id"
+	indexKey := appSubsIndexKey("app-id")
+	indexBytes, _ := json.Marshal([]string{subKey})
 
 	t.Run("error getting subscriptions", func(t *testing.T) {
 		mockAPI.On("KVGet", subKey).Return(nil, model.NewAppError("KVGet", "test", map[string]interface{}{}, "test error", 0)).Times(1)
@@ -116,11 +118,35 @@ func TestDeleteSub(t *testing.T) {
 	t.Run("subscription deleted", func(t *testing.T) {
 		mockAPI.On("KVGet", subKey).Return(storedSubsWithToDeleteBytes, nil).Times(1)
 		mockAPI.On("KVSetWithOptions", subKey, storedSubsBytes, mock.Anything).Return(true, nil).Times(1)
+		mockAPI.On("KVGet", indexKey).Return(indexBytes, nil).Times(1)
+		mockAPI.On("KVSetWithOptions", indexKey, []byte(nil), mock.Anything).Return(true, nil).Times(1)
 		err := s.DeleteSub(&toDelete)
 		require.NoError(t, err)
 	})
 }
 
+func TestDeleteSubKeepsIndex(t *testing.T) {
+	s, _ := newMemKVStore()
+
+	// Both subscriptions are stored with the same key, the team is ignored.
+	global := &apps.Subscription{Subject: apps.SubjectUserCreated, AppID: "app-id"}
+	team := &apps.Subscription{Subject: apps.SubjectUserCreated, TeamID: "team-id", AppID: "app-id"}
+	require.NoError(t, s.StoreSub(global))
+	require.NoError(t, s.StoreSub(team))
+
+	err := s.DeleteSub(global)
+	require.NoError(t, err)
+	subs, err := s.ListSubs("app-id")
+	require.NoError(t, err)
+	require.Equal(t, []*apps.Subscription{team}, subs)
+
+	err = s.DeleteSub(team)
+	require.NoError(t, err)
+	keys, err := s.getAppSubsIndex("app-id")
+	require.NoError(t, err)
+	require.Empty(t, keys)
+}
+
 func TestGetSubs(t *testing.T) {
 	botID := "bot-id"
 	mockAPI := &plugintest.API{}
@@ -250,6 +276,8 @@ func TestStoreSub(t *testing.T) {
 	emptySubsWithToStoreBytes, _ := json.Marshal(emptySubsWithToStore)
 
 	subKey := "sub_user_joined_channel_channel-id"
+	indexKey := appSubsIndexKey("app-id")
+	indexBytes, _ := json.Marshal([]string{subKey})
 
 	t.Run("error getting subscriptions", func(t *testing.T) {
 		mockAPI.On("KVGet", subKey).Return(nil, model.NewAppError("KVGet", "test", map[string]interface{}{}, "test error", 0)).Times(1)
@@ -261,6 +289,8 @@ func TestStoreSub(t *testing.T) {
 	t.Run("no value for subs key", func(t *testing.T) {
 		mockAPI.On("KVGet", subKey).Return(nil, nil).Times(1)
 		mockAPI.On("KVSetWithOptions", subKey, emptySubsWithToStoreBytes, mock.Anything).Return(true, nil).Times(1)
+		mockAPI.On("KVGet", indexKey).Return(nil, nil).Times(1)
+		mockAPI.On("KVSetWithOptions", indexKey, indexBytes, mock.Anything).Return(true, nil).Times(1)
 		err := s.StoreSub(&toStore)
 		require.NoError(t, err)
 	})
@@ -268,6 +298,8 @@ func TestStoreSub(t *testing.T) {
 	t.Run("empty list for subs key", func(t *testing.T) {
 		mockAPI.On("KVGet", subKey).Return(emptySubsBytes, nil).Times(1)
 		mockAPI.On("KVSetWithOptions", subKey, emptySubsWithToStoreBytes, mock.Anything).Return(true, nil).Times(1)
+		mockAPI.On("KVGet", indexKey).Return(nil, nil).Times(1)
+		mockAPI.On("KVSetWithOptions", indexKey, indexBytes, mock.Anything).Return(true, nil).Times(1)
 		err := s.StoreSub(&toStore)
 		require.NoError(t, err)
 	})
@@ -277,12 +309,22 @@ func TestStoreSub(t *testing.T) {
 		mockAPI.On("KVSetWithOptions", subKey, storedSubsWithToStoreBytes, mock.Anything).Return(false, model.NewAppError("KVSet", "test", map[string]interface{}{}, "test error", 0)).Times(1)
 		err := s.StoreSub(&toStore)
 		require.Error(t, err)
-		require.Equal(t, "KVSet: test, test error", err.Error())
+		require.Equal(t, "failed to save subscriptions: KVSet: test, test error", err.Error())
 	})
 
 	t.Run("subscription stored", func(t *testing.T) {
 		mockAPI.On("KVGet", subKey).Return(storedSubsBytes, nil).Times(1)
 		mockAPI.On("KVSetWithOptions", subKey, storedSubsWithToStoreBytes, mock.Anything).Return(true, nil).Times(1)
+		mockAPI.On("KVGet", indexKey).Return(nil, nil).Times(1)
+		mockAPI.On("KVSetWithOptions", indexKey, indexBytes, mock.Anything).Return(true, nil).Times(1)
+		err := s.StoreSub(&toStore)
+		require.NoError(t, err)
+	})
+
+	t.Run("already indexed", func(t *testing.T) {
+		mockAPI.On("KVGet", subKey).Return(storedSubsWithToStoreBytes, nil).Times(1)
+		mockAPI.On("KVSetWithOptions", subKey, storedSubsWithToStoreBytes, mock.Anything).Return(true, nil).Times(1)
+		mockAPI.On("KVGet", indexKey).Return(indexBytes, nil).Times(1)
 		err := s.StoreSub(&toStore)
 		require.NoError(t, err)
 	})
@@ -338,12 +380,66 @@ func TestSubsKey(t *testing.T) {
 			"channel-id",
 			"sub_channel_created_team-id",
 		},
+		string(apps.SubjectChannelArchived): {
+			apps.SubjectChannelArchived,
+			"team-id",
+			"channel-id",
+			"sub_channel_archived_team-id",
+		},
+		string(apps.SubjectChannelRestored): {
+			apps.SubjectChannelRestored,
+			"team-id",
+			"channel-id",
+			"sub_channel_restored_team-id",
+		},
+		string(apps.SubjectChannelUpdated): {
+			apps.SubjectChannelUpdated,
+			"team-id",
+			"channel-id",
+			"sub_channel_updated_team-id",
+		},
+		string(apps.SubjectTeamCreated): {
+			apps.SubjectTeamCreated,
+			"team-id",
+			"channel-id",
+			"sub_team_created",
+		},
+		string(apps.SubjectTeamUpdated): {
+			apps.SubjectTeamUpdated,
+			"team-id",
+			"channel-id",
+			"sub_team_updated_team-id",
+		},
 		string(apps.SubjectPostCreated): {
 			apps.SubjectPostCreated,
 			"team-id",
 			"channel-id",
 			"sub_post_created_channel-id",
 		},
+		"post_created team-wide": {
+			apps.SubjectPostCreated,
+			"team-id",
+			"",
+			"sub_post_created_t_team-id",
+		},
+		"post_created server-wide": {
+			apps.SubjectPostCreated,
+			"",
+			"",
+			"sub_post_created",
+		},
+		"user_joined_channel team-wide": {
+			apps.SubjectUserJoinedChannel,
+			"team-id",
+			"",
+			"sub_user_joined_channel_t_team-id",
+		},
+		string(apps.SubjectPostUpdated): {
+			apps.SubjectPostUpdated,
+			"team-id",
+			"channel-id",
+			"sub_post_updated_channel-id",
+		},
 	} {
 		t.Run(name, func(t *testing.T) {
 			r := s.subsKey(testcase.Subject, testcase.TeamID, testcase.ChannelID)
@@ -351,3 +447,204 @@ func TestSubsKey(t *testing.T) {
 		})
 	}
 }
+
+func TestUserSubs(t *testing.T) {
+	botID := "bot-id"
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+
+	apiClient := pluginapi.NewClient(mockAPI)
+	conf := configurator.NewConfigurator(apiClient, &configurator.BuildConfig{}, botID)
+	s := NewService(apiClient, conf)
+
+	for _, subject := range []apps.Subject{apps.SubjectUserCreated, apps.SubjectUserUpdated} {
+		t.Run(string(subject), func(t *testing.T) {
+			toStore := apps.Subscription{
+				Subject: subject,
+				AppID:   "app-id",
+			}
+			storedSubs := []*apps.Subscription{&toStore}
+			storedSubsBytes, _ := json.Marshal(storedSubs)
+			subKey := "sub_" + string(subject)
+			indexKey := appSubsIndexKey("app-id")
+			indexBytes, _ := json.Marshal([]string{subKey})
+
+			// The subscriptions are global, the team and channel are ignored.
+			mockAPI.On("KVGet", subKey).Return(nil, nil).Times(1)
+			mockAPI.On("KVSetWithOptions", subKey, storedSubsBytes, mock.Anything).Return(true, nil).Times(1)
+			mockAPI.On("KVGet", indexKey).Return(nil, nil).Times(1)
+			mockAPI.On("KVSetWithOptions", indexKey, indexBytes, mock.Anything).Return(true, nil).Times(1)
+			err := s.StoreSub(&toStore)
+			require.NoError(t, err)
+
+			mockAPI.On("KVGet", subKey).Return(storedSubsBytes, nil).Times(2)
+			subs, err := s.GetSubs(subject, "team-id", "channel-id")
+			require.NoError(t, err)
+			require.Equal(t, storedSubs, subs)
+			subs, err = s.GetSubs(subject, "", "")
+			require.NoError(t, err)
+			require.Equal(t, storedSubs, subs)
+
+			mockAPI.On("KVGet", subKey).Return(storedSubsBytes, nil).Times(1)
+			mockAPI.On("KVSetWithOptions", subKey, []byte("[]"), mock.Anything).Return(true, nil).Times(1)
+			mockAPI.On("KVGet", indexKey).Return(indexBytes, nil).Times(1)
+			mockAPI.On("KVSetWithOptions", indexKey, []byte(nil), mock.Anything).Return(true, nil).Times(1)
+			err = s.DeleteSub(&toStore)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestFindSubs(t *testing.T) {
+	botID := "bot-id"
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+
+	apiClient := pluginapi.NewClient(mockAPI)
+	conf := configurator.NewConfigurator(apiClient, &configurator.BuildConfig{}, botID)
+	s := NewService(apiClient, conf)
+
+	channelSubs := []*apps.Subscription{
+		{Subject: "post_created", ChannelID: "channel-id", AppID: "test1"},
+	}
+	channelSubsBytes, _ := json.Marshal(channelSubs)
+	teamSubs := []*apps.Subscription{
+		{Subject: "post_created", TeamID: "team-id", AppID: "test2"},
+	}
+	teamSubsBytes, _ := json.Marshal(teamSubs)
+	globalSubs := []*apps.Subscription{
+		{Subject: "post_created", AppID: "test3"},
+	}
+	globalSubsBytes, _ := json.Marshal(globalSubs)
+
+	t.Run("merged", func(t *testing.T) {
+		mockAPI.On("KVGet", "sub_post_created_channel-id").Return(channelSubsBytes, nil).Times(1)
+		mockAPI.On("KVGet", "sub_post_created_t_team-id").Return(teamSubsBytes, nil).Times(1)
+		mockAPI.On("KVGet", "sub_post_created").Return(globalSubsBytes, nil).Times(1)
+		subs, err := s.FindSubs("post_created", "team-id", "channel-id")
+		require.NoError(t, err)
+		require.Equal(t, []*apps.Subscription{channelSubs[0], teamSubs[0], globalSubs[0]}, subs)
+	})
+
+	t.Run("no team", func(t *testing.T) {
+		mockAPI.On("KVGet", "sub_post_created_channel-id").Return(nil, nil).Times(1)
+		mockAPI.On("KVGet", "sub_post_created").Return(globalSubsBytes, nil).Times(1)
+		subs, err := s.FindSubs("post_created", "", "channel-id")
+		require.NoError(t, err)
+		require.Equal(t, globalSubs, subs)
+	})
+
+	t.Run("none", func(t *testing.T) {
+		mockAPI.On("KVGet", "sub_post_created_channel-id").Return(nil, nil).Times(1)
+		mockAPI.On("KVGet", "sub_post_created_t_team-id").Return(nil, nil).Times(1)
+		mockAPI.On("KVGet", "sub_post_created").Return(nil, nil).Times(1)
+		_, err := s.FindSubs("post_created", "team-id", "channel-id")
+		require.Equal(t, utils.ErrNotFound, err)
+	})
+
+	t.Run("not channel-scoped", func(t *testing.T) {
+		mockAPI.On("KVGet", "sub_channel_created_team-id").Return(nil, nil).Times(1)
+		_, err := s.FindSubs("channel_created", "team-id", "channel-id")
+		require.Equal(t, utils.ErrNotFound, err)
+	})
+}
+
+func TestListAndDeleteAllSubs(t *testing.T) {
+	botID := "bot-id"
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+
+	apiClient := pluginapi.NewClient(mockAPI)
+	conf := configurator.NewConfigurator(apiClient, &configurator.BuildConfig{}, botID)
+	s := NewService(apiClient, conf)
+
+	channelSubs := []*apps.Subscription{
+		{Subject: "post_created", ChannelID: "channel-id", AppID: "other-app-id"},
+		{Subject: "post_created", ChannelID: "channel-id", AppID: "app-id"},
+	}
+	channelSubsBytes, _ := json.Marshal(channelSubs)
+	userSubs := []*apps.Subscription{
+		{Subject: "user_created", AppID: "app-id"},
+	}
+	userSubsBytes, _ := json.Marshal(userSubs)
+	indexKey := appSubsIndexKey("app-id")
+	indexBytes, _ := json.Marshal([]string{"sub_post_created_channel-id", "sub_user_created"})
+
+	t.Run("no subscriptions", func(t *testing.T) {
+		mockAPI.On("KVGet", indexKey).Return(nil, nil).Times(1)
+		subs, err := s.ListSubs("app-id")
+		require.NoError(t, err)
+		require.Empty(t, subs)
+	})
+
+	t.Run("listed", func(t *testing.T) {
+		mockAPI.On("KVGet", indexKey).Return(indexBytes, nil).Times(1)
+		mockAPI.On("KVGet", "sub_post_created_channel-id").Return(channelSubsBytes, nil).Times(1)
+		mockAPI.On("KVGet", "sub_user_created").Return(userSubsBytes, nil).Times(1)
+		subs, err := s.ListSubs("app-id")
+		require.NoError(t, err)
+		require.Equal(t, []*apps.Subscription{channelSubs[1], userSubs[0]}, subs)
+	})
+
+	t.Run("all deleted", func(t *testing.T) {
+		remainingBytes, _ := json.Marshal(channelSubs[:1])
+		mockAPI.On("KVGet", indexKey).Return(indexBytes, nil).Times(1)
+		mockAPI.On("KVGet", "sub_post_created_channel-id").Return(channelSubsBytes, nil).Times(1)
+		mockAPI.On("KVSetWithOptions", "sub_post_created_channel-id", remainingBytes, mock.Anything).Return(true, nil).Times(1)
+		mockAPI.On("KVGet", "sub_user_created").Return(userSubsBytes, nil).Times(1)
+		mockAPI.On("KVSetWithOptions", "sub_user_created", []byte("[]"), mock.Anything).Return(true, nil).Times(1)
+		mockAPI.On("KVSetWithOptions", indexKey, []byte(nil), mock.Anything).Return(true, nil).Times(1)
+		err := s.DeleteAllSubs("app-id")
+		require.NoError(t, err)
+	})
+}
+
+func TestMigrateAppSubsIndex(t *testing.T) {
+	s, kv := newMemKVStore()
+
+	// Subscriptions stored before there was a per-App index.
+	channelSubs := []*apps.Subscription{
+		{Subject: "post_created", ChannelID: "channel-id", AppID: "other-app-id"},
+		{Subject: "post_created", ChannelID: "channel-id", AppID: "app-id"},
+	}
+	userSubs := []*apps.Subscription{
+		{Subject: "user_created", AppID: "app-id"},
+	}
+	teamSubs := []*apps.Subscription{
+		{Subject: "user_joined_channel", TeamID: "team-id", AppID: "other-app-id"},
+	}
+	kv.data["sub_post_created_channel-id"], _ = json.Marshal(channelSubs)
+	kv.data["sub_user_created"], _ = json.Marshal(userSubs)
+	kv.data["sub_user_joined_channel_t_team-id"], _ = json.Marshal(teamSubs)
+	kv.data[prefixSubFailingSince+"not-a-sub"] = []byte("1")
+
+	subs, err := s.ListSubs("app-id")
+	require.NoError(t, err)
+	require.Empty(t, subs)
+
+	err = s.MigrateAppSubsIndex()
+	require.NoError(t, err)
+	subs, err = s.ListSubs("app-id")
+	require.NoError(t, err)
+	require.Equal(t, []*apps.Subscription{channelSubs[1], userSubs[0]}, subs)
+	subs, err = s.ListSubs("other-app-id")
+	require.NoError(t, err)
+	require.Equal(t, []*apps.Subscription{channelSubs[0], teamSubs[0]}, subs)
+
+	// The team-wide subscriptions are flagged.
+	has, err := s.HasTeamSubs("user_joined_channel")
+	require.NoError(t, err)
+	require.True(t, has)
+	has, err = s.HasTeamSubs("post_created")
+	require.NoError(t, err)
+	require.False(t, has)
+
+	t.Run("runs once", func(t *testing.T) {
+		delete(kv.data, appSubsIndexKey("app-id"))
+		err = s.MigrateAppSubsIndex()
+		require.NoError(t, err)
+		subs, err = s.ListSubs("app-id")
+		require.NoError(t, err)
+		require.Empty(t, subs)
+	})
+}
@@ -11,11 +11,71 @@ const (
 	SubjectUserLeftChannel   = Subject("user_left_channel")
 	SubjectUserJoinedTeam    = Subject("user_joined_team")
 	SubjectUserLeftTeam      = Subject("user_left_team")
-	SubjectUserUpdated       = Subject("user_updated")
 	SubjectChannelCreated    = Subject("channel_created")
-	SubjectPostCreated       = Subject("post_created")
+
+	// SubjectChannelArchived, SubjectChannelRestored and SubjectChannelUpdated
+	// are detected by polling, since the server has no hooks for them. The
+	// plugin API can only list the public channels of a team, so they are
+	// not notified for private channels.
+	SubjectChannelArchived = Subject("channel_archived")
+	SubjectChannelRestored = Subject("channel_restored")
+	SubjectChannelUpdated  = Subject("channel_updated")
+
+	SubjectTeamCreated = Subject("team_created")
+	SubjectTeamUpdated = Subject("team_updated")
+	SubjectPostCreated = Subject("post_created")
+	SubjectPostUpdated = Subject("post_updated")
+
+	// SubjectUserUpdated, SubjectPostDeleted, SubjectReactionAdded and
+	// SubjectReactionRemoved are not supported yet: the Mattermost server
+	// the plugin is built against has no hooks for them. See
+	// Subject.IsSupported.
+	SubjectUserUpdated     = Subject("user_updated")
+	SubjectPostDeleted     = Subject("post_deleted")
+	SubjectReactionAdded   = Subject("reaction_added")
+	SubjectReactionRemoved = Subject("reaction_removed")
+
+	// SubjectSubscriptionExpiring is sent to an App shortly before one of its
+	// subscriptions expires, so that it can renew it by subscribing again. It
+	// can not be subscribed to.
+	SubjectSubscriptionExpiring = Subject("subscription_expiring")
 )
 
+// IsSupported returns false for the subjects that have no source of events,
+// since the plugin hooks they need are not in the Mattermost server the plugin
+// is built against. The subscriptions to them would never be notified.
+func (s Subject) IsSupported() bool {
+	switch s {
+	case SubjectUserUpdated, SubjectPostDeleted, SubjectReactionAdded, SubjectReactionRemoved:
+		return false
+	}
+	return true
+}
+
+// RequiredPermission returns the permission an App needs to be granted to
+// subscribe to the subject, if any.
+func (s Subject) RequiredPermission() PermissionType {
+	switch s {
+	case SubjectUserCreated, SubjectUserUpdated:
+		return PermissionUserNotification
+	}
+	return ""
+}
+
+// IsChannelScoped returns true for the subjects about events in a channel.
+// Subscriptions to them can also be team-wide (no ChannelID), or server-wide
+// (no ChannelID and TeamID), in which case they only cover public channels.
+func (s Subject) IsChannelScoped() bool {
+	switch s {
+	case SubjectUserJoinedChannel,
+		SubjectUserLeftChannel,
+		SubjectPostCreated,
+		SubjectPostUpdated:
+		return true
+	}
+	return false
+}
+
 type Subscription struct {
 	AppID   AppID   `json:"app_id"`
 	Subject Subject `json:"subject"`
@@ -25,15 +85,62 @@ type Subscription struct {
 	TeamID    string `json:"team_id,omitempty"`
 
 	Expand *Expand `json:"expand,omitempty"`
+
+	// Filter applies to the subjects that have a post in their context.
+	Filter *PostFilter `json:"filter,omitempty"`
+
+	// ExpiresAt is when the subscription is purged, in milliseconds since the
+	// epoch, unless the App renews it by subscribing again. 0 never expires.
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+
+	// SuspendedAt is set by the proxy when it suspends the subscription since
+	// the deliveries to the App have kept failing for too long, see the
+	// SubscriptionSuspendAfterHours setting. Subscribing again resumes it.
+	SuspendedAt int64 `json:"suspended_at,omitempty"`
 }
 
 type Notification struct {
 	Subject Subject
 	Context *Context
+
+	// Subscription is set for SubjectSubscriptionExpiring.
+	Subscription *Subscription `json:",omitempty"`
+}
+
+// IsWide returns true for team-wide and server-wide subscriptions to
+// channel-scoped subjects.
+func (sub *Subscription) IsWide() bool {
+	return sub.Subject.IsChannelScoped() && sub.ChannelID == ""
+}
+
+// RequiredPermissions returns the permissions an App needs to be granted for
+// the subscription.
+func (sub *Subscription) RequiredPermissions() Permissions {
+	var out Permissions
+	if p := sub.Subject.RequiredPermission(); p != "" {
+		out = append(out, p)
+	}
+	if sub.IsWide() {
+		if sub.TeamID != "" {
+			out = append(out, PermissionTeamWideNotification)
+		} else {
+			out = append(out, PermissionServerWideNotification)
+		}
+	}
+	return out
 }
 
 func (sub *Subscription) EqualScope(other *Subscription) bool {
 	s1, s2 := *sub, *other
 	s1.Expand, s2.Expand = nil, nil
+	s1.Filter, s2.Filter = nil, nil
+	s1.ExpiresAt, s2.ExpiresAt = 0, 0
+	s1.SuspendedAt, s2.SuspendedAt = 0, 0
 	return s1 == s2
 }
+
+// IsExpired returns true if the subscription has expired at now, in
+// milliseconds since the epoch.
+func (sub *Subscription) IsExpired(now int64) bool {
+	return sub.ExpiresAt != 0 && sub.ExpiresAt <= now
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package apps
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+type ThreadFilter string
+
+const (
+	ThreadFilterRootOnly    = ThreadFilter("root_only")
+	ThreadFilterRepliesOnly = ThreadFilter("replies_only")
+)
+
+// PostFilter narrows down the posts an App is notified about. All of the
+// specified conditions must match. Filters are evaluated before the context
+// is expanded, and before the App is called.
+type PostFilter struct {
+	// MessageRegexp is matched against the message.
+	MessageRegexp string `json:"message_regexp,omitempty"`
+
+	// Keywords match if any of them is contained in the message, ignoring
+	// case.
+	Keywords []string `json:"keywords,omitempty"`
+
+	// Hashtag matches the posts with the hashtag, with or without the "#".
+	Hashtag string `json:"hashtag,omitempty"`
+
+	// ExcludeBots excludes the posts made by bots and webhooks,
+	// ExcludeOwnBot only the posts made by the App's own bot.
+	ExcludeBots   bool `json:"exclude_bots,omitempty"`
+	ExcludeOwnBot bool `json:"exclude_own_bot,omitempty"`
+
+	// PostTypes matches the post types, use "" for regular posts.
+	PostTypes []string `json:"post_types,omitempty"`
+
+	Thread ThreadFilter `json:"thread,omitempty"`
+
+	messageRegexp *regexp.Regexp
+}
+
+// compileMessageRegexp compiles MessageRegexp once per loaded filter.
+func (f *PostFilter) compileMessageRegexp() (*regexp.Regexp, error) {
+	if f.messageRegexp != nil {
+		return f.messageRegexp, nil
+	}
+	re, err := regexp.Compile(f.MessageRegexp)
+	if err != nil {
+		return nil, err
+	}
+	f.messageRegexp = re
+	return re, nil
+}
+
+func (f *PostFilter) Validate() error {
+	if f.MessageRegexp != "" {
+		_, err := f.compileMessageRegexp()
+		if err != nil {
+			return errors.Wrap(err, "invalid message_regexp")
+		}
+	}
+	switch f.Thread {
+	case "", ThreadFilterRootOnly, ThreadFilterRepliesOnly:
+	default:
+		return errors.Errorf("invalid thread filter %q", f.Thread)
+	}
+	return nil
+}
+
+// Matches returns true if the post passes the filter. botUserID is the App's
+// own bot.
+func (f *PostFilter) Matches(post *model.Post, botUserID string) bool {
+	if post == nil {
+		return false
+	}
+
+	switch f.Thread {
+	case ThreadFilterRootOnly:
+		if post.RootId != "" {
+			return false
+		}
+	case ThreadFilterRepliesOnly:
+		if post.RootId == "" {
+			return false
+		}
+	}
+
+	if len(f.PostTypes) > 0 && !containsString(f.PostTypes, post.Type) {
+		return false
+	}
+
+	if f.ExcludeOwnBot && botUserID != "" && post.UserId == botUserID {
+		return false
+	}
+	if f.ExcludeBots && (post.GetProp("from_bot") == "true" || post.GetProp("from_webhook") == "true") {
+		return false
+	}
+
+	if f.Hashtag != "" {
+		tag := "#" + strings.ToLower(strings.TrimPrefix(f.Hashtag, "#"))
+		if !containsString(strings.Fields(strings.ToLower(post.Hashtags)), tag) {
+			return false
+		}
+	}
+
+	if len(f.Keywords) > 0 {
+		message := strings.ToLower(post.Message)
+		found := false
+		for _, keyword := range f.Keywords {
+			if strings.Contains(message, strings.ToLower(keyword)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if f.MessageRegexp != "" {
+		re, err := f.compileMessageRegexp()
+		if err != nil || !re.MatchString(post.Message) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, current := range list {
+		if current == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package apps
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostFilterMatches(t *testing.T) {
+	newPost := func(f func(*model.Post)) *model.Post {
+		p := &model.Post{
+			Id:       "post-id",
+			UserId:   "user-id",
+			Message:  "Deploy the Release to production",
+			Hashtags: "#Deploy #prod",
+		}
+		if f != nil {
+			f(p)
+		}
+		return p
+	}
+
+	for _, tc := range []struct {
+		name     string
+		filter   PostFilter
+		post     *model.Post
+		expected bool
+	}{
+		{"empty", PostFilter{}, newPost(nil), true},
+		{"nil post", PostFilter{}, nil, false},
+		{"regexp match", PostFilter{MessageRegexp: `(?i)release\s+to`}, newPost(nil), true},
+		{"regexp no match", PostFilter{MessageRegexp: `^release`}, newPost(nil), false},
+		{"keyword match", PostFilter{Keywords: []string{"rollback", "RELEASE"}}, newPost(nil), true},
+		{"keyword no match", PostFilter{Keywords: []string{"rollback"}}, newPost(nil), false},
+		{"hashtag match", PostFilter{Hashtag: "deploy"}, newPost(nil), true},
+		{"hashtag with # match", PostFilter{Hashtag: "#PROD"}, newPost(nil), true},
+		{"hashtag no match", PostFilter{Hashtag: "dep"}, newPost(nil), false},
+		{"exclude bots, user", PostFilter{ExcludeBots: true}, newPost(nil), true},
+		{"exclude bots, bot", PostFilter{ExcludeBots: true}, newPost(func(p *model.Post) { p.AddProp("from_bot", "true") }), false},
+		{"exclude bots, webhook", PostFilter{ExcludeBots: true}, newPost(func(p *model.Post) { p.AddProp("from_webhook", "true") }), false},
+		{"exclude own bot", PostFilter{ExcludeOwnBot: true}, newPost(func(p *model.Post) { p.UserId = "bot-user-id" }), false},
+		{"exclude own bot, other bot", PostFilter{ExcludeOwnBot: true}, newPost(func(p *model.Post) { p.AddProp("from_bot", "true") }), true},
+		{"regular post type", PostFilter{PostTypes: []string{""}}, newPost(nil), true},
+		{"system post type", PostFilter{PostTypes: []string{""}}, newPost(func(p *model.Post) { p.Type = model.POST_JOIN_CHANNEL }), false},
+		{"root only, root", PostFilter{Thread: ThreadFilterRootOnly}, newPost(nil), true},
+		{"root only, reply", PostFilter{Thread: ThreadFilterRootOnly}, newPost(func(p *model.Post) { p.RootId = "root-id" }), false},
+		{"replies only, root", PostFilter{Thread: ThreadFilterRepliesOnly}, newPost(nil), false},
+		{"replies only, reply", PostFilter{Thread: ThreadFilterRepliesOnly}, newPost(func(p *model.Post) { p.RootId = "root-id" }), true},
+		{"all conditions", PostFilter{Keywords: []string{"deploy"}, Hashtag: "prod", ExcludeBots: true, Thread: ThreadFilterRootOnly}, newPost(nil), true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.filter.Matches(tc.post, "bot-user-id"))
+		})
+	}
+}
+
+func TestPostFilterValidate(t *testing.T) {
+	require.NoError(t, (&PostFilter{MessageRegexp: `^deploy`, Thread: ThreadFilterRootOnly}).Validate())
+	require.Error(t, (&PostFilter{MessageRegexp: `(`}).Validate())
+	require.EqualError(t, (&PostFilter{Thread: "threads"}).Validate(), `invalid thread filter "threads"`)
+}
+
+func TestCompileMessageRegexp(t *testing.T) {
+	f := &PostFilter{MessageRegexp: `^cached`}
+	re, err := f.compileMessageRegexp()
+	require.NoError(t, err)
+	again, err := f.compileMessageRegexp()
+	require.NoError(t, err)
+	require.Same(t, re, again)
+
+	f = &PostFilter{MessageRegexp: `(`}
+	_, err = f.compileMessageRegexp()
+	require.Error(t, err)
+	require.Nil(t, f.messageRegexp)
+}
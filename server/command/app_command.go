@@ -0,0 +1,63 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package command
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+)
+
+// executeAppCommand executes the slash commands registered for the Apps'
+// /command bindings, and renders the Call response.
+func (s *service) executeAppCommand(params *params) (*model.CommandResponse, error) {
+	args := params.commandArgs
+	cc := &apps.Context{
+		ActingUserID: args.UserId,
+		TeamID:       args.TeamId,
+		ChannelID:    args.ChannelId,
+		RootPostID:   args.RootId,
+	}
+
+	res, err := s.apps.API.ExecuteCommand(cc, args.Command)
+	if err != nil {
+		return normalOut(params, nil, err)
+	}
+
+	switch res.Type {
+	case apps.CallResponseTypeOK:
+		return &model.CommandResponse{
+			Text:         string(res.Markdown),
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		}, nil
+
+	case apps.CallResponseTypeError:
+		return normalOut(params, nil, responseError(res))
+
+	case apps.CallResponseTypeNavigate:
+		return &model.CommandResponse{
+			GotoLocation: res.NavigateToURL,
+		}, nil
+	}
+	if len(res.FieldErrors) > 0 {
+		return normalOut(params, nil, responseError(res))
+	}
+	return normalOut(params, nil,
+		errors.Errorf("%s responses are not supported for commands yet", res.Type))
+}
+
+// responseError includes the field errors of a response, since there is no
+// form to show them in.
+func responseError(res *apps.CallResponse) error {
+	message := res.Error
+	if message == "" {
+		message = "invalid arguments"
+	}
+	return &apps.CommandError{
+		Message:     message,
+		FieldErrors: res.FieldErrors,
+	}
+}
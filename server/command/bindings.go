@@ -0,0 +1,27 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package command
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/utils/md"
+)
+
+// executeBindingsErrors lists the Apps whose bindings currently fail to be
+// fetched, for system administrators. The errors are the ones seen by the node
+// that handles the command.
+func (s *service) executeBindingsErrors(params *params) (*model.CommandResponse, error) {
+	if !s.apps.Mattermost.User.HasPermissionTo(params.commandArgs.UserId, model.PERMISSION_MANAGE_SYSTEM) {
+		return normalOut(params, nil, errors.New("you need to be a system administrator to list bindings errors"))
+	}
+
+	bindingsErrors := s.apps.API.GetBindingsErrors()
+	if len(bindingsErrors) == 0 {
+		return normalOut(params, md.MD("No bindings errors on this server node."), nil)
+	}
+	return normalOut(params, md.JSONBlock(bindingsErrors), nil)
+}
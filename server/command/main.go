@@ -21,12 +21,16 @@ type params struct {
 func (s *service) handleMain(in *params) (*model.CommandResponse, error) {
 	subcommands := map[string]func(*params) (*model.CommandResponse, error){
 		"info":                s.executeInfo,
+		"bindings-errors":     s.executeBindingsErrors,
 		"install":             s.executeInstall,
 		"debug-install-hello": s.executeDebugInstallHello,
 		"debug-clean":         s.executeDebugClean,
 		"debug-bindings":      s.executeDebugBindings,
 		"debug-embedded":      s.executeDebugEmbedded,
 		"experimental":        s.executeExperimentalInstall,
+		"oauth2-provider":     s.executeOAuth2Provider,
+		"subscriptions":       s.executeSubscriptions,
+		"uninstall":           s.executeUninstall,
 	}
 
 	return runSubcommand(subcommands, in)
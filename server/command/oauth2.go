@@ -0,0 +1,43 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package command
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+	"github.com/mattermost/mattermost-plugin-apps/server/utils/md"
+)
+
+// executeOAuth2Provider configures the client credentials for a third-party
+// OAuth2 provider declared by an App.
+func (s *service) executeOAuth2Provider(params *params) (*model.CommandResponse, error) {
+	appID := ""
+	providerID := ""
+	client := apps.RemoteOAuth2Client{}
+	fs := pflag.NewFlagSet("", pflag.ContinueOnError)
+	fs.StringVar(&appID, "app-id", "", "App ID")
+	fs.StringVar(&providerID, "provider-id", "", "OAuth2 provider ID, as declared in the App's manifest")
+	fs.StringVar(&client.ClientID, "client-id", "", "OAuth2 client ID")
+	fs.StringVar(&client.ClientSecret, "client-secret", "", "OAuth2 client secret")
+
+	err := fs.Parse(params.current)
+	if err != nil {
+		return normalOut(params, nil, err)
+	}
+
+	if !s.apps.Mattermost.User.HasPermissionTo(params.commandArgs.UserId, model.PERMISSION_MANAGE_SYSTEM) {
+		return normalOut(params, nil, errors.New("you need to be a system administrator to configure OAuth2 providers"))
+	}
+
+	err = s.apps.API.ConfigureRemoteOAuth2(apps.AppID(appID), providerID, &client)
+	if err != nil {
+		return normalOut(params, nil, err)
+	}
+
+	return normalOut(params, md.Markdownf("Configured OAuth2 provider %s for %s.", providerID, appID), nil)
+}
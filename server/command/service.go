@@ -55,15 +55,13 @@ func (s *service) ExecuteCommand(pluginContext *plugin.Context, commandArgs *mod
 			errors.New("invalid arguments to command.Handler. Please contact your system administrator"))
 	}
 	split := strings.Fields(commandArgs.Command)
+	if len(split) > 0 && split[0] != "/"+apps.CommandTrigger {
+		return s.executeAppCommand(params)
+	}
 	if len(split) < 2 {
 		return normalOut(params, nil,
 			errors.New("no subcommand specified, nothing to do"))
 	}
-	command := split[0]
-	if command != "/"+apps.CommandTrigger {
-		return normalOut(params, nil,
-			errors.Errorf("%q is not a supported command and should not have been invoked. Please contact your system administrator", command))
-	}
 	params.current = split[1:]
 
 	return s.handleMain(params)
@@ -0,0 +1,49 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package command
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+	"github.com/mattermost/mattermost-plugin-apps/server/utils/md"
+)
+
+func (s *service) executeSubscriptions(in *params) (*model.CommandResponse, error) {
+	subcommands := map[string]func(*params) (*model.CommandResponse, error){
+		"list": s.executeSubscriptionsList,
+	}
+
+	return runSubcommand(subcommands, in)
+}
+
+func (s *service) executeSubscriptionsList(params *params) (*model.CommandResponse, error) {
+	appID := ""
+	fs := pflag.NewFlagSet("", pflag.ContinueOnError)
+	fs.StringVar(&appID, "app-id", "", "App ID")
+
+	err := fs.Parse(params.current)
+	if err != nil {
+		return normalOut(params, nil, err)
+	}
+	if appID == "" {
+		return normalOut(params, nil, errors.New("--app-id is required"))
+	}
+
+	if !s.apps.Mattermost.User.HasPermissionTo(params.commandArgs.UserId, model.PERMISSION_MANAGE_SYSTEM) {
+		return normalOut(params, nil, errors.New("you need to be a system administrator to list subscriptions"))
+	}
+
+	subs, err := s.apps.API.ListSubscriptions(apps.AppID(appID))
+	if err != nil {
+		return normalOut(params, nil, err)
+	}
+	if len(subs) == 0 {
+		return normalOut(params, md.Markdownf("%s has no subscriptions.", appID), nil)
+	}
+	return normalOut(params, md.JSONBlock(subs), nil)
+}
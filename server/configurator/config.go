@@ -8,11 +8,28 @@ import (
 // config.
 type StoredConfig struct {
 	Apps map[string]interface{}
+
+	// MaxExpandThreadPosts and MaxExpandChannelHistoryPosts limit how many of
+	// the most recent posts can be included in the expanded context.
+	MaxExpandThreadPosts         int
+	MaxExpandChannelHistoryPosts int
+
+	// SubscriptionSuspendAfterHours is how long the deliveries of a
+	// subscription may keep failing before it is suspended.
+	SubscriptionSuspendAfterHours int
+
+	// EncryptionKey is used to encrypt the secrets stored in the KV store,
+	// e.g. the third-party OAuth2 credentials and tokens.
+	EncryptionKey string
 }
 
 func (sc *StoredConfig) ConfigMap() map[string]interface{} {
 	return map[string]interface{}{
-		"Apps": sc.Apps,
+		"Apps":                          sc.Apps,
+		"MaxExpandThreadPosts":          sc.MaxExpandThreadPosts,
+		"MaxExpandChannelHistoryPosts":  sc.MaxExpandChannelHistoryPosts,
+		"SubscriptionSuspendAfterHours": sc.SubscriptionSuspendAfterHours,
+		"EncryptionKey":                 sc.EncryptionKey,
 	}
 }
 
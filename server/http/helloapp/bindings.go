@@ -12,9 +12,13 @@ import (
 // pre-determined by the server.
 func (h *helloapp) bindings(w http.ResponseWriter, req *http.Request, claims *apps.JWTClaims, cc *apps.Context) (int, error) {
 	sendSurvey := h.makeCall(PathSendSurvey)
+	sendSurvey.Expand = &apps.Expand{ActingUserAccessToken: apps.ExpandAll}
 
 	c := *sendSurvey
-	c.Expand = &apps.Expand{Post: apps.ExpandAll}
+	c.Expand = &apps.Expand{
+		Post:                  apps.ExpandAll,
+		ActingUserAccessToken: apps.ExpandAll,
+	}
 
 	sendSurveyModal := &c
 	sendSurveyModal.Type = apps.CallTypeForm
@@ -52,34 +56,41 @@ func (h *helloapp) bindings(w http.ResponseWriter, req *http.Request, claims *ap
 				},
 			},
 		},
-		// TODO /Command binding is a placeholder, may not be final, test!
 		{
 			Location: apps.LocationCommand,
 			Bindings: []*apps.Binding{
 				{
-					Label:       "message",
-					Location:    "message",
-					Hint:        "[--user] message",
-					Description: "send a message to a user",
-					Call:        sendSurvey,
-				}, {
-					Label:       "manage",
-					Location:    "manage",
-					Hint:        "subscribe | unsubscribe ",
-					Description: "manage channel subscriptions to greet new users",
+					Label:       "hello",
+					Location:    "hello",
+					Hint:        "message | manage",
+					Description: "Hallo სამყარო test app commands",
 					Bindings: []*apps.Binding{
 						{
-							Label:       "subscribe",
-							Location:    "subscribe",
-							Hint:        "[--channel]",
-							Description: "subscribes a channel to greet new users",
-							Call:        h.makeCall(PathSubscribeChannel, "mode", "on"),
+							Label:       "message",
+							Location:    "message",
+							Hint:        "[--user] message",
+							Description: "send a message to a user",
+							Call:        sendSurvey,
 						}, {
-							Label:       "unsubscribe",
-							Location:    "unsubscribe",
-							Hint:        "[--channel]",
-							Description: "unsubscribes a channel from greeting new users",
-							Call:        h.makeCall(PathSubscribeChannel, "mode", "off"),
+							Label:       "manage",
+							Location:    "manage",
+							Hint:        "subscribe | unsubscribe ",
+							Description: "manage channel subscriptions to greet new users",
+							Bindings: []*apps.Binding{
+								{
+									Label:       "subscribe",
+									Location:    "subscribe",
+									Hint:        "[--channel]",
+									Description: "subscribes a channel to greet new users",
+									Call:        h.makeUserCall(PathSubscribeChannel, "mode", "on"),
+								}, {
+									Label:       "unsubscribe",
+									Location:    "unsubscribe",
+									Hint:        "[--channel]",
+									Description: "unsubscribes a channel from greeting new users",
+									Call:        h.makeUserCall(PathSubscribeChannel, "mode", "off"),
+								},
+							},
 						},
 					},
 				},
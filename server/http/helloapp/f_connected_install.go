@@ -22,7 +22,7 @@ func (h *helloapp) fConnectedInstall(w http.ResponseWriter, req *http.Request, c
 	var team *model.Team
 	var channel *model.Channel
 
-	err := h.asUser(c.Context.ActingUserID,
+	err := h.asUser(c.Context,
 		func(mmclient *model.Client4) error {
 			var api4Resp *model.Response
 			teams, api4Resp = mmclient.GetAllTeams("", 0, 1)
@@ -85,8 +85,7 @@ func (h *helloapp) fConnectedInstall(w http.ResponseWriter, req *http.Request, c
 			})
 			h.dm(c.Context.ActingUserID, "Posted welcome message to channel.")
 
-			// TODO this should be done using the REST Subs API, for now mock with direct use
-			err = h.apps.API.Subscribe(&apps.Subscription{
+			err = h.subscribe(mmclient, &apps.Subscription{
 				AppID:     AppID,
 				Subject:   apps.SubjectUserJoinedChannel,
 				ChannelID: channel.Id,
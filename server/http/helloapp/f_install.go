@@ -27,17 +27,13 @@ func (h *helloapp) fInstall(w http.ResponseWriter, req *http.Request, claims *ap
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
-	err = h.initOAuther()
-	if err != nil {
-		return http.StatusInternalServerError, err
-	}
-
 	connectURL, err := h.startOAuth2Connect(c.Context.ActingUserID, &apps.Call{
 		URL:     h.appURL(PathConnectedInstall),
 		Context: c.Context,
 		Expand: &apps.Expand{
-			App:    apps.ExpandAll,
-			Config: apps.ExpandSummary,
+			App:                   apps.ExpandAll,
+			Config:                apps.ExpandSummary,
+			ActingUserAccessToken: apps.ExpandAll,
 		},
 	})
 	if err != nil {
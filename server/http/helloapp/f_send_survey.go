@@ -32,17 +32,18 @@ func (h *helloapp) newSendSurveyFormResponse(claims *apps.JWTClaims, c *apps.Cal
 					AutocompleteHint: "enter user ID or @user",
 					ModalLabel:       "User",
 				}, {
-					Name:             fieldMessage,
-					Type:             apps.FieldTypeText,
-					TextSubtype:      "textarea",
-					IsRequired:       true,
-					Description:      "Text to ask the user about",
-					Label:            "message",
-					AutocompleteHint: "Anything you want to say",
-					ModalLabel:       "Text",
-					TextMinLength:    2,
-					TextMaxLength:    1024,
-					Value:            message,
+					Name:                 fieldMessage,
+					Type:                 apps.FieldTypeText,
+					TextSubtype:          "textarea",
+					IsRequired:           true,
+					Description:          "Text to ask the user about",
+					Label:                "message",
+					AutocompleteHint:     "Anything you want to say",
+					AutocompletePosition: 1,
+					ModalLabel:           "Text",
+					TextMinLength:        2,
+					TextMaxLength:        1024,
+					Value:                message,
 				},
 			},
 		},
@@ -61,8 +62,8 @@ func (h *helloapp) fSendSurvey(w http.ResponseWriter, req *http.Request, claims
 
 		// TODO this should be done with expanding mentions, make a ticket
 		if strings.HasPrefix(userID, "@") {
-			_ = h.asUser(c.Context.ActingUserID, func(c *model.Client4) error {
-				user, _ := c.GetUserByUsername(userID[1:], "")
+			_ = h.asUser(c.Context, func(mmclient *model.Client4) error {
+				user, _ := mmclient.GetUserByUsername(userID[1:], "")
 				if user != nil {
 					userID = user.Id
 				}
@@ -3,7 +3,6 @@ package helloapp
 import (
 	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/mattermost/mattermost-plugin-apps/server/apps"
 	"github.com/mattermost/mattermost-plugin-apps/server/utils/httputils"
@@ -37,8 +36,9 @@ func (h *helloapp) fSubscribe(w http.ResponseWriter, req *http.Request, claims *
 		return http.StatusOK, nil
 	}
 
-	channelName := c.Values["channel"]
-	if channelName == "" {
+	// The channel is resolved to its ID by the proxy.
+	channelID := c.GetValue("channel", "")
+	if channelID == "" {
 		out := apps.CallResponse{
 			Type:  apps.CallResponseTypeError,
 			Error: "Missing channel in form submission",
@@ -48,12 +48,13 @@ func (h *helloapp) fSubscribe(w http.ResponseWriter, req *http.Request, claims *
 		return http.StatusBadRequest, nil
 	}
 
-	channelName = strings.TrimPrefix(channelName, "~")
-	err := h.asUser(c.Context.ActingUserID, func(client *model.Client4) error {
-		ch, res := client.GetChannelByName(channelName, c.Context.TeamID, "")
+	channelName := channelID
+	err := h.asUser(c.Context, func(client *model.Client4) error {
+		ch, res := client.GetChannel(channelID, "")
 		if res.Error != nil {
-			return errors.Wrapf(res.Error, "error fetching channel %v from team %v", channelName, c.Context.TeamID)
+			return errors.Wrapf(res.Error, "error fetching channel %v", channelID)
 		}
+		channelName = ch.Name
 
 		_, res = client.CreatePost(&model.Post{
 			UserId:    c.Context.ActingUserID,
@@ -76,7 +77,7 @@ func (h *helloapp) fSubscribe(w http.ResponseWriter, req *http.Request, claims *
 		httputils.WriteJSON(w, out)
 		return http.StatusBadRequest, nil
 	}
-	msg := md.Markdownf("Set subscription status to %v for channel %v", c.Values["mode"], channelName)
+	msg := md.Markdownf("Set subscription status to %v for channel %v", c.GetValue("mode", ""), channelName)
 	out := apps.CallResponse{Markdown: msg}
 
 	httputils.WriteJSON(w, out)
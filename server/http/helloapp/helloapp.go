@@ -10,8 +10,6 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 
-	"github.com/mattermost/mattermost-plugin-api/experimental/oauther"
-
 	"github.com/mattermost/mattermost-plugin-apps/server/apps"
 	"github.com/mattermost/mattermost-plugin-apps/server/utils/httputils"
 )
@@ -30,11 +28,9 @@ const (
 )
 
 const (
-	PathManifest       = "/mattermost-app.json"
-	PathInstall        = apps.AppInstallPath  // convention for Mattermost Apps
-	PathBindings       = apps.AppBindingsPath // convention for Mattermost Apps
-	PathOAuth2         = "/oauth2"            // convention for Mattermost Apps, comes from OAuther
-	PathOAuth2Complete = "/oauth2/complete"   // convention for Mattermost Apps, comes from OAuther
+	PathManifest = "/mattermost-app.json"
+	PathInstall  = apps.AppInstallPath  // convention for Mattermost Apps
+	PathBindings = apps.AppBindingsPath // convention for Mattermost Apps
 
 	PathConnectedInstall = "/connected_install"
 	PathSendSurvey       = "/send"
@@ -45,8 +41,7 @@ const (
 )
 
 type helloapp struct {
-	apps    *apps.Service
-	OAuther oauther.OAuther
+	apps *apps.Service
 }
 
 // Init hello app router
@@ -58,7 +53,6 @@ func Init(router *mux.Router, appsService *apps.Service) {
 	r := router.PathPrefix(apps.HelloAppPath).Subrouter()
 	r.HandleFunc(PathManifest, h.handleManifest).Methods("GET")
 	handleGetWithContext(r, PathBindings, h.bindings)
-	r.PathPrefix(PathOAuth2).HandlerFunc(h.handleOAuth).Methods("GET")
 
 	// Naming convention: fXXX are "Callable" functions, nXXX are notification
 	// handlers.
@@ -69,8 +63,6 @@ func Init(router *mux.Router, appsService *apps.Service) {
 	handleCall(r, PathSubscribeChannel, h.fSubscribe)
 
 	handleNotify(r, PathNotifyUserJoinedChannel, h.nUserJoinedChannel)
-
-	_ = h.initOAuther()
 }
 
 type contextHandler func(http.ResponseWriter, *http.Request, *apps.JWTClaims, *apps.Context) (int, error)
@@ -178,3 +170,10 @@ func (h *helloapp) appURL(path string) string {
 func (h *helloapp) makeCall(path string, namevalues ...string) *apps.Call {
 	return apps.MakeCall(h.appURL(path), namevalues...)
 }
+
+// makeUserCall makes a Call that acts as the user, see asUser.
+func (h *helloapp) makeUserCall(path string, namevalues ...string) *apps.Call {
+	c := h.makeCall(path, namevalues...)
+	c.Expand = &apps.Expand{ActingUserAccessToken: apps.ExpandAll}
+	return c
+}
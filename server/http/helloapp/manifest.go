@@ -25,7 +25,6 @@ func (h *helloapp) handleManifest(w http.ResponseWriter, req *http.Request) {
 				apps.LocationCommand,
 				apps.LocationInPost,
 			},
-			OAuth2CallbackURL: h.appURL(PathOAuth2Complete),
-			HomepageURL:       h.appURL("/"),
+			HomepageURL: h.appURL("/"),
 		})
 }
@@ -1,23 +1,23 @@
 package helloapp
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 
 	"github.com/mattermost/mattermost-server/v5/model"
 	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
 )
 
-func (h *helloapp) asUser(userID string, f func(*model.Client4) error) error {
-	t, err := h.OAuther.GetToken(userID)
-	if err != nil {
-		return err
-	}
-	if t == nil {
-		return errors.Errorf("OAuth token not found for user %s", userID)
+// asUser requires the Call to expand ActingUserAccessToken.
+func (h *helloapp) asUser(cc *apps.Context, f func(*model.Client4) error) error {
+	if cc.ActingUserAccessToken == "" {
+		return errors.Errorf("OAuth token not found for user %s", cc.ActingUserID)
 	}
 	mmClient := model.NewAPIv4Client(h.apps.Configurator.GetConfig().MattermostSiteURL)
-	mmClient.SetOAuthToken(t.AccessToken)
+	mmClient.SetOAuthToken(cc.ActingUserAccessToken)
 	return f(mmClient)
 }
 
@@ -33,6 +33,22 @@ func (h *helloapp) asBot(f func(mmclient *model.Client4, botUserID string) error
 	return f(mmClient, creds.BotUserID)
 }
 
+// subscribe subscribes the App using the proxy's REST API. The subscription is
+// made on the App's behalf when mmclient uses the bot's access token.
+func (h *helloapp) subscribe(mmclient *model.Client4, sub *apps.Subscription) error {
+	data, err := json.Marshal(sub)
+	if err != nil {
+		return err
+	}
+	url := h.apps.Configurator.GetConfig().PluginURL + apps.APIPath + apps.SubscribePath
+	resp, appErr := mmclient.DoApiRequest(http.MethodPost, url, string(data), "")
+	if appErr != nil {
+		return appErr
+	}
+	defer resp.Body.Close()
+	return nil
+}
+
 func (h *helloapp) postAsBot(post *model.Post) (*model.Post, error) {
 	var createdPost *model.Post
 	err := h.asBot(func(mmclient *model.Client4, botUserID string) error {
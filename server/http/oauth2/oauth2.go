@@ -0,0 +1,82 @@
+package oauth2
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+	"github.com/mattermost/mattermost-plugin-apps/server/utils/httputils"
+)
+
+type oauth2 struct {
+	apps *apps.Service
+}
+
+// Init registers the OAuth2 connect flow for the users of the installed Apps,
+// at <plugin>/oauth2/{app_id}/connect and <plugin>/oauth2/{app_id}/complete,
+// and for the third-party providers declared by the Apps, at
+// <plugin>/remote-oauth2/{app_id}/{provider_id}/connect and .../complete.
+func Init(router *mux.Router, appsService *apps.Service) {
+	o := oauth2{
+		apps: appsService,
+	}
+
+	router.PathPrefix(apps.OAuth2Path + "/{app_id}").HandlerFunc(o.handleOAuth2).Methods("GET")
+
+	remote := router.PathPrefix(apps.RemoteOAuth2Path + "/{app_id}/{provider_id}").Subrouter()
+	remote.HandleFunc(apps.RemoteOAuth2ConnectPath, checkAuthorized(o.handleRemoteConnect)).Methods("GET")
+	remote.HandleFunc(apps.RemoteOAuth2CompletePath, checkAuthorized(o.handleRemoteComplete)).Methods("GET")
+}
+
+func (o *oauth2) handleOAuth2(w http.ResponseWriter, req *http.Request) {
+	appID := apps.AppID(mux.Vars(req)["app_id"])
+	oauther, err := o.apps.API.GetOAuther(appID)
+	if err != nil {
+		httputils.WriteNotFoundError(w, err)
+		return
+	}
+	oauther.ServeHTTP(w, req)
+}
+
+func (o *oauth2) handleRemoteConnect(w http.ResponseWriter, req *http.Request, actingUserID string) {
+	vars := mux.Vars(req)
+	redirectURL, err := o.apps.API.StartRemoteOAuth2Connect(apps.AppID(vars["app_id"]), vars["provider_id"], actingUserID)
+	if err != nil {
+		httputils.WriteBadRequestError(w, err)
+		return
+	}
+	http.Redirect(w, req, redirectURL, http.StatusFound)
+}
+
+func (o *oauth2) handleRemoteComplete(w http.ResponseWriter, req *http.Request, actingUserID string) {
+	vars := mux.Vars(req)
+	q := req.URL.Query()
+	if e := q.Get("error"); e != "" {
+		httputils.WriteBadRequestError(w, errors.Errorf("authorization failed: %s %s", e, q.Get("error_description")))
+		return
+	}
+
+	err := o.apps.API.CompleteRemoteOAuth2Connect(apps.AppID(vars["app_id"]), vars["provider_id"], actingUserID, q.Get("state"), q.Get("code"))
+	if err != nil {
+		httputils.WriteBadRequestError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	_, _ = w.Write([]byte(`<!DOCTYPE html><html><head><script>window.close();</script></head>` +
+		`<body><p>Completed connecting your account. Please close this window.</p></body></html>`))
+}
+
+func checkAuthorized(f func(http.ResponseWriter, *http.Request, string)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		actingUserID := req.Header.Get("Mattermost-User-Id")
+		if actingUserID == "" {
+			httputils.WriteUnauthorizedError(w, errors.New("not authorized"))
+			return
+		}
+
+		f(w, req, actingUserID)
+	}
+}
@@ -3,6 +3,8 @@ package restapi
 import (
 	"net/http"
 
+	"github.com/pkg/errors"
+
 	"github.com/mattermost/mattermost-plugin-apps/server/apps"
 	"github.com/mattermost/mattermost-plugin-apps/server/utils/httputils"
 )
@@ -23,3 +25,27 @@ func (a *restapi) handleGetBindings(w http.ResponseWriter, req *http.Request, ac
 
 	httputils.WriteJSON(w, bindings)
 }
+
+// handleRefreshBindings invalidates the cached bindings of the App, for Apps
+// and system administrators, who pass the app_id.
+func (a *restapi) handleRefreshBindings(w http.ResponseWriter, req *http.Request) {
+	appID, err := a.authenticateApp(req)
+	if err != nil {
+		httputils.WriteUnauthorizedError(w, err)
+		return
+	}
+	if appID == "" {
+		appID = apps.AppID(req.URL.Query().Get(apps.PropAppID))
+		if appID == "" {
+			httputils.WriteBadRequestError(w, errors.New("app_id is required"))
+			return
+		}
+	}
+
+	err = a.apps.API.InvalidateBindings(appID)
+	if err != nil {
+		httputils.WriteInternalServerError(w, err)
+		return
+	}
+	httputils.WriteJSON(w, map[string]string{"status": "OK"})
+}
@@ -25,6 +25,9 @@ func (a *restapi) handleCall(w http.ResponseWriter, req *http.Request) {
 	}
 	call.Context.ActingUserID = actingUserID
 
+	// The expanded context is populated by the proxy, never by the client.
+	call.Context.ExpandedContext = apps.ExpandedContext{}
+
 	res, err := a.apps.API.Call(call)
 	if err != nil {
 		httputils.WriteInternalServerError(w, err)
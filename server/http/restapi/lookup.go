@@ -0,0 +1,47 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package restapi
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+	"github.com/mattermost/mattermost-plugin-apps/server/utils/httputils"
+)
+
+type LookupResponse struct {
+	Items []apps.SelectOption `json:"items"`
+}
+
+// handleLookup fetches the options of a dynamic select field. The Call is to
+// the field's SelectSourceURL, with the form's current values, and the
+// user's partial input in Query.
+func (a *restapi) handleLookup(w http.ResponseWriter, req *http.Request, actingUserID string) {
+	call, err := apps.UnmarshalCallFromReader(req.Body)
+	if err != nil {
+		httputils.WriteBadRequestError(w, errors.Wrap(err, "Failed to unmarshal Call struct"))
+		return
+	}
+	if call.Context == nil {
+		httputils.WriteBadRequestError(w, errors.New("context is required"))
+		return
+	}
+	call.Context.ActingUserID = actingUserID
+
+	// The expanded context is populated by the proxy, never by the client.
+	call.Context.ExpandedContext = apps.ExpandedContext{}
+
+	options, err := a.apps.API.Lookup(call)
+	if err == apps.ErrLookupSuperseded {
+		httputils.WriteJSONError(w, http.StatusConflict, "", err)
+		return
+	}
+	if err != nil {
+		httputils.WriteInternalServerError(w, err)
+		return
+	}
+	httputils.WriteJSON(w, LookupResponse{Items: options})
+}
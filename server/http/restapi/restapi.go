@@ -2,11 +2,13 @@ package restapi
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 
 	pluginapi "github.com/mattermost/mattermost-plugin-api"
+	"github.com/mattermost/mattermost-server/v5/model"
 
 	"github.com/mattermost/mattermost-plugin-apps/server/apps"
 	"github.com/mattermost/mattermost-plugin-apps/server/utils/httputils"
@@ -30,8 +32,11 @@ func Init(router *mux.Router, appsService *apps.Service) {
 
 	subrouter := router.PathPrefix(apps.APIPath).Subrouter()
 	subrouter.HandleFunc(apps.BindingsPath, checkAuthorized(a.handleGetBindings)).Methods("GET")
+	subrouter.HandleFunc(apps.RefreshBindingsPath, a.handleRefreshBindings).Methods("POST")
 	subrouter.HandleFunc(apps.CallPath, a.handleCall).Methods("POST")
+	subrouter.HandleFunc(apps.LookupPath, checkAuthorized(a.handleLookup)).Methods("POST")
 	subrouter.HandleFunc(apps.SubscribePath, a.handleSubscribe).Methods("POST", "DELETE")
+	subrouter.HandleFunc(apps.SubscriptionsPath, checkAuthorized(a.handleListSubscriptions)).Methods("GET")
 }
 
 func checkAuthorized(f func(http.ResponseWriter, *http.Request, string)) func(http.ResponseWriter, *http.Request) {
@@ -45,3 +50,35 @@ func checkAuthorized(f func(http.ResponseWriter, *http.Request, string)) func(ht
 		f(w, req, actingUserID)
 	}
 }
+
+// authenticateApp returns the ID of the App making the request. Apps
+// authenticate with a JWT signed with their secret, in the
+// apps.OutgoingAuthHeader header, or with their bot's access token. System
+// administrators may act on behalf of any App, in which case the returned ID is
+// empty.
+func (a *restapi) authenticateApp(r *http.Request) (apps.AppID, error) {
+	authValue := r.Header.Get(apps.OutgoingAuthHeader)
+	if authValue != "" {
+		if !strings.HasPrefix(authValue, "Bearer ") {
+			return "", errors.Errorf("invalid %s header", apps.OutgoingAuthHeader)
+		}
+		app, err := a.apps.API.AuthenticateAppJWT(strings.TrimPrefix(authValue, "Bearer "))
+		if err != nil {
+			return "", err
+		}
+		return app.Manifest.AppID, nil
+	}
+
+	actingUserID := r.Header.Get("Mattermost-User-ID")
+	if actingUserID == "" {
+		return "", errors.New("user not logged in")
+	}
+	app, err := a.apps.API.GetAppByBotUserID(actingUserID)
+	if err == nil {
+		return app.Manifest.AppID, nil
+	}
+	if !a.mm.User.HasPermissionTo(actingUserID, model.PERMISSION_MANAGE_SYSTEM) {
+		return "", errors.New("not an app or a system administrator")
+	}
+	return "", nil
+}
@@ -4,13 +4,16 @@ import (
 	"encoding/json"
 	"net/http"
 
-	"github.com/mattermost/mattermost-plugin-apps/server/apps"
 	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+
+	"github.com/mattermost/mattermost-plugin-apps/server/apps"
+	"github.com/mattermost/mattermost-plugin-apps/server/utils/httputils"
 )
 
 func (a *restapi) handleSubscribe(w http.ResponseWriter, r *http.Request) {
 	var err error
-	actingUserID := ""
 	// logMessage := ""
 	status := http.StatusInternalServerError
 
@@ -25,22 +28,22 @@ func (a *restapi) handleSubscribe(w http.ResponseWriter, r *http.Request) {
 		_ = json.NewEncoder(w).Encode(resp)
 	}()
 
-	actingUserID = r.Header.Get("Mattermost-User-ID")
-	if actingUserID == "" {
-		err = errors.New("user not logged in")
+	appID, err := a.authenticateApp(r)
+	if err != nil {
 		status = http.StatusUnauthorized
 		return
 	}
-	// TODO check for sysadmin
 
 	var sub apps.Subscription
 	if err = json.NewDecoder(r.Body).Decode(&sub); err != nil {
-		status = http.StatusUnauthorized
+		status = http.StatusBadRequest
 		return
 	}
+	// An App can only subscribe on its own behalf.
+	if appID != "" {
+		sub.AppID = appID
+	}
 
-	// TODO replace with an appropriate API-level call that would validate,
-	// deduplicate, etc.
 	switch r.Method {
 	case http.MethodPost:
 		err = a.apps.API.Subscribe(&sub)
@@ -49,7 +52,29 @@ func (a *restapi) handleSubscribe(w http.ResponseWriter, r *http.Request) {
 	default:
 	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		status = http.StatusBadRequest
+		return
+	}
+	status = http.StatusOK
+}
+
+// handleListSubscriptions lists the subscriptions of an App, for system
+// administrators.
+func (a *restapi) handleListSubscriptions(w http.ResponseWriter, r *http.Request, actingUserID string) {
+	if !a.mm.User.HasPermissionTo(actingUserID, model.PERMISSION_MANAGE_SYSTEM) {
+		httputils.WriteUnauthorizedError(w, errors.New("not a system administrator"))
+		return
+	}
+	appID := apps.AppID(r.URL.Query().Get(apps.PropAppID))
+	if appID == "" {
+		httputils.WriteBadRequestError(w, errors.New("app_id is required"))
+		return
+	}
+
+	subs, err := a.apps.API.ListSubscriptions(appID)
+	if err != nil {
+		httputils.WriteInternalServerError(w, err)
 		return
 	}
+	httputils.WriteJSON(w, subs)
 }
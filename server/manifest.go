@@ -24,6 +24,45 @@ const manifestStr = `
       "windows-amd64": "server/dist/plugin-windows-amd64.exe"
     },
     "executable": ""
+  },
+  "settings_schema": {
+    "header": "",
+    "footer": "",
+    "settings": [
+      {
+        "key": "MaxExpandThreadPosts",
+        "display_name": "Thread expand limit:",
+        "type": "number",
+        "help_text": "The maximum number of the most recent thread posts that an App can receive when it requests to expand the thread.",
+        "placeholder": "",
+        "default": 20
+      },
+      {
+        "key": "MaxExpandChannelHistoryPosts",
+        "display_name": "Channel history expand limit:",
+        "type": "number",
+        "help_text": "The maximum number of the most recent channel posts that an App can receive when it requests to expand the channel history.",
+        "placeholder": "",
+        "default": 20
+      },
+      {
+        "key": "SubscriptionSuspendAfterHours",
+        "display_name": "Suspend failing subscriptions after (hours):",
+        "type": "number",
+        "help_text": "How long the notifications of a subscription may keep failing to be delivered to the App before the subscription is suspended. The App resumes it by subscribing again.",
+        "placeholder": "",
+        "default": 72
+      },
+      {
+        "key": "EncryptionKey",
+        "display_name": "Encryption key:",
+        "type": "generated",
+        "help_text": "The key used to encrypt the third-party OAuth2 credentials and tokens stored by the plugin. Regenerating it disconnects all users from the third-party services.",
+        "regenerate_help_text": "Regenerates the encryption key. All users will need to re-connect their accounts to the third-party services.",
+        "placeholder": "",
+        "default": null
+      }
+    ]
   }
 }
 `
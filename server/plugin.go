@@ -5,11 +5,13 @@ package main
 
 import (
 	gohttp "net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 
 	pluginapi "github.com/mattermost/mattermost-plugin-api"
+	"github.com/mattermost/mattermost-plugin-api/cluster"
 	"github.com/mattermost/mattermost-server/v5/model"
 	"github.com/mattermost/mattermost-server/v5/plugin"
 
@@ -20,15 +22,24 @@ import (
 	"github.com/mattermost/mattermost-plugin-apps/server/http"
 	"github.com/mattermost/mattermost-plugin-apps/server/http/dialog"
 	"github.com/mattermost/mattermost-plugin-apps/server/http/helloapp"
+	"github.com/mattermost/mattermost-plugin-apps/server/http/oauth2"
 	"github.com/mattermost/mattermost-plugin-apps/server/http/restapi"
 )
 
+const (
+	lifecyclePollInterval      = time.Minute
+	subscriptionsCheckInterval = time.Hour
+	encryptionKeyLength        = 32
+)
+
 type Plugin struct {
 	plugin.MattermostPlugin
 	*configurator.BuildConfig
 	mattermost *pluginapi.Client
 
 	apps         *apps.Service
+	lifecycleJob *cluster.Job
+	subsJob      *cluster.Job
 	command      command.Service
 	configurator configurator.Service
 	http         http.Service
@@ -53,11 +64,16 @@ func (p *Plugin) OnActivate() error {
 	}
 
 	p.configurator = configurator.NewConfigurator(p.mattermost, p.BuildConfig, botUserID)
+	err = p.OnConfigurationChange()
+	if err != nil {
+		return errors.Wrap(err, "failed to load the plugin configuration")
+	}
 	p.apps = impl.NewService(p.mattermost, p.configurator)
 
 	p.http = http.NewService(mux.NewRouter(), p.apps,
 		dialog.Init,
 		helloapp.Init,
+		oauth2.Init,
 		restapi.Init,
 	)
 
@@ -65,6 +81,54 @@ func (p *Plugin) OnActivate() error {
 	if err != nil {
 		return errors.Wrap(err, "failed to initialize own command handling")
 	}
+
+	// The Apps' commands are registered in the background, since the Apps'
+	// bindings are fetched for them.
+	go p.apps.API.RegisterCommands()
+
+	// Index the subscriptions that were stored before there was a per-App index.
+	go func() {
+		err := p.apps.API.MigrateAppSubsIndex()
+		if err != nil {
+			p.mattermost.Log.Warn("failed to migrate the app subscriptions index", "err", err.Error())
+		}
+	}()
+
+	// Team and channel changes that have no plugin hooks are polled for.
+	p.lifecycleJob, err = cluster.Schedule(p.API, "apps_lifecycle",
+		cluster.MakeWaitForInterval(lifecyclePollInterval),
+		func() {
+			err := p.apps.API.PollLifecycleChanges()
+			if err != nil {
+				p.mattermost.Log.Warn("failed to poll for team and channel changes", "err", err.Error())
+			}
+		})
+	if err != nil {
+		return errors.Wrap(err, "failed to schedule the team and channel changes job")
+	}
+
+	// Expired subscriptions are purged, and failing ones suspended.
+	p.subsJob, err = cluster.Schedule(p.API, "apps_subscriptions",
+		cluster.MakeWaitForInterval(subscriptionsCheckInterval),
+		func() {
+			err := p.apps.API.CheckSubscriptions()
+			if err != nil {
+				p.mattermost.Log.Warn("failed to check subscriptions", "err", err.Error())
+			}
+		})
+	if err != nil {
+		return errors.Wrap(err, "failed to schedule the subscriptions job")
+	}
+	return nil
+}
+
+func (p *Plugin) OnDeactivate() error {
+	if p.lifecycleJob != nil {
+		_ = p.lifecycleJob.Close()
+	}
+	if p.subsJob != nil {
+		_ = p.subsJob.Close()
+	}
 	return nil
 }
 
@@ -76,7 +140,21 @@ func (p *Plugin) OnConfigurationChange() error {
 
 	stored := configurator.StoredConfig{}
 	_ = p.mattermost.Configuration.LoadPluginConfiguration(&stored)
-	return p.configurator.Refresh(&stored)
+	err := p.configurator.Refresh(&stored)
+	if err != nil {
+		return err
+	}
+
+	// The encryption key is generated once, saving it causes another
+	// configuration change.
+	if stored.EncryptionKey == "" {
+		stored.EncryptionKey = model.NewRandomString(encryptionKeyLength)
+		err = p.configurator.Store(&stored)
+		if err != nil {
+			return errors.Wrap(err, "failed to save the generated encryption key")
+		}
+	}
+	return nil
 }
 
 func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
@@ -112,6 +190,10 @@ func (p *Plugin) MessageHasBeenPosted(pluginContext *plugin.Context, post *model
 	_ = p.apps.API.Notify(apps.NewPostContext(post), apps.SubjectPostCreated)
 }
 
+func (p *Plugin) MessageHasBeenUpdated(pluginContext *plugin.Context, newPost, oldPost *model.Post) {
+	_ = p.apps.API.Notify(apps.NewPostContext(newPost), apps.SubjectPostUpdated)
+}
+
 func (p *Plugin) ChannelHasBeenCreated(pluginContext *plugin.Context, ch *model.Channel) {
 	_ = p.apps.API.Notify(apps.NewChannelContext(ch), apps.SubjectChannelCreated)
 }
@@ -0,0 +1,33 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See License for license information.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/plugin"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHooksImplemented checks that all the Plugin methods that take a
+// *plugin.Context are hooks of the Mattermost server the plugin is built
+// against. The server only calls the methods that are in plugin.Hooks, the
+// others would silently never be invoked.
+func TestHooksImplemented(t *testing.T) {
+	hooks := reflect.TypeOf((*plugin.Hooks)(nil)).Elem()
+	pluginContext := reflect.TypeOf(&plugin.Context{})
+
+	p := reflect.TypeOf(&Plugin{})
+	for i := 0; i < p.NumMethod(); i++ {
+		m := p.Method(i)
+		// The receiver is the first argument.
+		if m.Type.NumIn() < 2 || m.Type.In(1) != pluginContext {
+			continue
+		}
+		hook, ok := hooks.MethodByName(m.Name)
+		require.True(t, ok, "%s is not a hook in plugin.Hooks", m.Name)
+		require.Equal(t, hook.Type.NumIn(), m.Type.NumIn()-1, "%s does not match the hook's signature", m.Name)
+	}
+}